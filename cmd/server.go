@@ -11,16 +11,28 @@ import (
 	"os/signal"
 	"syscall"
 	"text/tabwriter"
+	"time"
 
 	"github.com/go-redis/redis"
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/oklog/oklog/pkg/group"
 	"github.com/opentracing/opentracing-go"
 	jaegerconfig "github.com/uber/jaeger-client-go/config"
 	"github.com/uber/jaeger-lib/metrics/prometheus"
+	"google.golang.org/grpc"
+
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+
+	"github.com/WiFeng/short-url/pb"
+	"github.com/WiFeng/short-url/pkg/analytics"
+	"github.com/WiFeng/short-url/pkg/auth"
 
 	"github.com/WiFeng/short-url/pkg/core/config"
 	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/dao"
 	"github.com/WiFeng/short-url/pkg/endpoint"
+	"github.com/WiFeng/short-url/pkg/filter"
+	"github.com/WiFeng/short-url/pkg/ratelimit"
 	"github.com/WiFeng/short-url/pkg/service"
 	"github.com/WiFeng/short-url/pkg/transport"
 
@@ -33,6 +45,7 @@ func main() {
 	var (
 		// httpAddr    = fs.String("http-addr", ":8081", "HTTP listen address")
 		environment = fs.String("env", "development", "Runing environment")
+		importRedis = fs.Bool("import-redis", false, "Import the redis storage into mysql and exit")
 	)
 	fs.Usage = usageFor(fs, os.Args[0]+" [flags]")
 	fs.Parse(os.Args[1:])
@@ -85,13 +98,33 @@ func main() {
 
 	// Create a db client
 	var db *sql.DB
-	{
+	if conf.Storage.Driver == dao.DriverMysql {
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true",
+			conf.Mysql.User, conf.Mysql.Password, conf.Mysql.Host, conf.Mysql.Port, conf.Mysql.Database)
+		db, err = sql.Open("mysql", dsn)
+		if err != nil {
+			logger.Fatalw("mysql open error", "err", err)
+			os.Exit(1)
+		}
+		db.SetMaxOpenConns(conf.Mysql.MaxOpenConns)
+		db.SetMaxIdleConns(conf.Mysql.MaxIdleConns)
+		defer db.Close()
+
+		if err := db.Ping(); err != nil {
+			logger.Fatalw("mysql ping error", "err", err)
+			os.Exit(1)
+		}
 
+		if err := dao.MigrateMysql(db); err != nil {
+			logger.Fatalw("mysql migrate error", "err", err)
+			os.Exit(1)
+		}
 	}
 
 	// Create a redis client
 	var redisCli *redis.Client
-	{
+	if *importRedis || conf.Storage.Driver == dao.DriverRedis || conf.Storage.Driver == "" || conf.Storage.Cache == dao.DriverRedis ||
+		conf.RateLimit.Enabled && (conf.RateLimit.Backend == ratelimit.BackendRedis || conf.RateLimit.Backend == "") {
 		addr := fmt.Sprintf("%s:%d", conf.Redis.Host, conf.Redis.Port)
 		pass := conf.Redis.Auth
 		db := conf.Redis.Db
@@ -107,6 +140,94 @@ func main() {
 		}
 	}
 
+	// Import the redis storage into mysql, or refuse to issue the codes which
+	// may be live in redis
+	if *importRedis {
+		if db == nil {
+			logger.Fatalw("import-redis requires the mysql driver", "driver", conf.Storage.Driver)
+			os.Exit(1)
+		}
+		imported, err := dao.ImportRedis(redisCli, db)
+		if err != nil {
+			logger.Fatalw("import redis error", "err", err)
+			os.Exit(1)
+		}
+		logger.Infow("redis imported", "links", imported.Links, "short_urls", imported.ShortURLs,
+			"clicks", imported.Clicks, "api_keys", imported.APIKeys)
+		return
+	}
+	if db != nil && redisCli != nil {
+		if err := dao.CheckRedisID(redisCli, db); err != nil {
+			logger.Fatalw("storage error", "err", err, "hint", "run with -import-redis")
+			os.Exit(1)
+		}
+	}
+
+	// Create the storage selected by config
+	var store dao.Storage
+	{
+		store, err = dao.NewStorage(conf, db, redisCli)
+		if err != nil {
+			logger.Fatalw("storage error", "driver", conf.Storage.Driver, "cache", conf.Storage.Cache, "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Create the filter of the destinations of links
+	var urlFilter *filter.Filter
+	{
+		urlFilter, err = filter.New(conf)
+		if err != nil {
+			logger.Fatalw("filter error", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Create the pipeline of click analytics
+	var pipeline *analytics.Pipeline
+	{
+		pipeline, err = analytics.NewPipeline(conf, store, logger)
+		if err != nil {
+			logger.Fatalw("analytics pipeline error", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Create the authenticator of the admin API, nil leaves it open
+	var authn auth.Authenticator
+	if conf.Auth.Enabled {
+		// no key could be issued, nor a token be verified
+		if conf.Auth.AdminKeyHash == "" && conf.Auth.JWT.JWKS == "" {
+			logger.Fatalw("auth enabled without admin key hash or jwks")
+			os.Exit(1)
+		}
+		authn = auth.NewKeyAuthenticator(store, conf.Auth.AdminKeyHash)
+
+		if jwtConf := conf.Auth.JWT; jwtConf.JWKS != "" {
+			keys, err := auth.NewJWKSSource(jwtConf.JWKS, time.Duration(jwtConf.RefreshInterval)*time.Second)
+			if err != nil {
+				logger.Fatalw("jwks error", "jwks", jwtConf.JWKS, "err", err)
+				os.Exit(1)
+			}
+			authn = auth.NewChainAuthenticator(authn, auth.NewJWTAuthenticator(keys, auth.JWTOptions{
+				Issuer:    jwtConf.Issuer,
+				Audience:  jwtConf.Audience,
+				ClockSkew: time.Duration(jwtConf.ClockSkew) * time.Second,
+				Scopes:    jwtConf.Scopes,
+			}))
+		}
+	}
+
+	// Create the rate limits of creates and redirects
+	var limits ratelimit.Limits
+	{
+		limits, err = ratelimit.New(conf, redisCli)
+		if err != nil {
+			logger.Fatalw("ratelimit error", "backend", conf.RateLimit.Backend, "err", err)
+			os.Exit(1)
+		}
+	}
+
 	// Build the layers of the service "onion" from the inside out. First, the
 	// business logic service; then, the set of endpoints that wrap the service;
 	// and finally, a series of concrete transport adapters. The adapters, like
@@ -114,9 +235,10 @@ func main() {
 	// the interfaces that the transports expect. Note that we're not binding
 	// them to ports or anything yet; we'll do that next.
 	var (
-		service     = service.New(conf, db, redisCli, logger)
-		endpoints   = endpoint.New(service, logger)
-		httpHandler = transport.NewHTTPHandler(endpoints, logger)
+		service     = service.New(conf, store, urlFilter, logger)
+		endpoints   = endpoint.New(service, pipeline, authn, limits, logger)
+		httpHandler = transport.NewHTTPHandler(endpoints, conf, logger)
+		grpcServer  = transport.NewGRPCServer(endpoints, logger)
 	)
 
 	var g group.Group
@@ -138,6 +260,34 @@ func main() {
 		})
 	}
 
+	if grpcAddr := conf.Server.GRPC.Addr; grpcAddr != "" {
+		// The gRPC listener mounts the Go kit gRPC server we created.
+		grpcListener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			logger.Fatalw("listen error", "transport", "gRPC", "during", "Listen", "err", err)
+			os.Exit(1)
+		}
+		g.Add(func() error {
+			logger.Infow("serve start", "transport", "gRPC", "addr", grpcAddr, "config", confFile)
+			// we add the Go Kit gRPC Interceptor to our gRPC service, which puts
+			// the method name into the context.
+			baseServer := grpc.NewServer(grpc.UnaryInterceptor(kitgrpc.Interceptor))
+			pb.RegisterShortURLServer(baseServer, grpcServer)
+			return baseServer.Serve(grpcListener)
+		}, func(error) {
+			grpcListener.Close()
+		})
+	}
+
+	{
+		// The pipeline records the clicks in the background.
+		g.Add(func() error {
+			return pipeline.Run()
+		}, func(error) {
+			pipeline.Close()
+		})
+	}
+
 	{
 		// This function just sits and waits for ctrl-C.
 		cancelInterrupt := make(chan struct{})
@@ -0,0 +1,525 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.12.3
+// source: short_url.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ShortURLClient is the client API for ShortURL service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ShortURLClient interface {
+	// Creates a short url of the long url.
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateReply, error)
+	// Creates the short urls of many long urls, with a result per item.
+	CreateBatch(ctx context.Context, in *CreateBatchRequest, opts ...grpc.CallOption) (*CreateBatchReply, error)
+	// Queries the long url of the short url.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryReply, error)
+	// Updates the metadata of the short url.
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateReply, error)
+	// Deletes the short url, it is kept but no longer redirected.
+	Delete(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*DeleteReply, error)
+	// Lists the short urls matching the filters, a page at a time.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReply, error)
+	// Counts the clicks of the short url.
+	Clicks(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*ClicksReply, error)
+	// Reports the stats of the clicks of the short url.
+	Stats(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*StatsReply, error)
+	// Groups the short urls matching the filters by an utm parameter, with
+	// their clicks.
+	GroupStats(ctx context.Context, in *GroupStatsRequest, opts ...grpc.CallOption) (*GroupStatsReply, error)
+	// Renders the short url as a QR code.
+	QRCode(ctx context.Context, in *QRCodeRequest, opts ...grpc.CallOption) (*QRCodeReply, error)
+	// Issues an API key.
+	IssueKey(ctx context.Context, in *IssueKeyRequest, opts ...grpc.CallOption) (*KeyReply, error)
+	// Revokes an API key.
+	RevokeKey(ctx context.Context, in *RevokeKeyRequest, opts ...grpc.CallOption) (*KeyReply, error)
+}
+
+type shortURLClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewShortURLClient(cc grpc.ClientConnInterface) ShortURLClient {
+	return &shortURLClient{cc}
+}
+
+func (c *shortURLClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateReply, error) {
+	out := new(CreateReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) CreateBatch(ctx context.Context, in *CreateBatchRequest, opts ...grpc.CallOption) (*CreateBatchReply, error) {
+	out := new(CreateBatchReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/CreateBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryReply, error) {
+	out := new(QueryReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/Query", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateReply, error) {
+	out := new(UpdateReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) Delete(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*DeleteReply, error) {
+	out := new(DeleteReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListReply, error) {
+	out := new(ListReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) Clicks(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*ClicksReply, error) {
+	out := new(ClicksReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/Clicks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) Stats(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*StatsReply, error) {
+	out := new(StatsReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) GroupStats(ctx context.Context, in *GroupStatsRequest, opts ...grpc.CallOption) (*GroupStatsReply, error) {
+	out := new(GroupStatsReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/GroupStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) QRCode(ctx context.Context, in *QRCodeRequest, opts ...grpc.CallOption) (*QRCodeReply, error) {
+	out := new(QRCodeReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/QRCode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) IssueKey(ctx context.Context, in *IssueKeyRequest, opts ...grpc.CallOption) (*KeyReply, error) {
+	out := new(KeyReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/IssueKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortURLClient) RevokeKey(ctx context.Context, in *RevokeKeyRequest, opts ...grpc.CallOption) (*KeyReply, error) {
+	out := new(KeyReply)
+	err := c.cc.Invoke(ctx, "/pb.ShortURL/RevokeKey", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ShortURLServer is the server API for ShortURL service.
+// All implementations should embed UnimplementedShortURLServer
+// for forward compatibility
+type ShortURLServer interface {
+	// Creates a short url of the long url.
+	Create(context.Context, *CreateRequest) (*CreateReply, error)
+	// Creates the short urls of many long urls, with a result per item.
+	CreateBatch(context.Context, *CreateBatchRequest) (*CreateBatchReply, error)
+	// Queries the long url of the short url.
+	Query(context.Context, *QueryRequest) (*QueryReply, error)
+	// Updates the metadata of the short url.
+	Update(context.Context, *UpdateRequest) (*UpdateReply, error)
+	// Deletes the short url, it is kept but no longer redirected.
+	Delete(context.Context, *QueryRequest) (*DeleteReply, error)
+	// Lists the short urls matching the filters, a page at a time.
+	List(context.Context, *ListRequest) (*ListReply, error)
+	// Counts the clicks of the short url.
+	Clicks(context.Context, *QueryRequest) (*ClicksReply, error)
+	// Reports the stats of the clicks of the short url.
+	Stats(context.Context, *QueryRequest) (*StatsReply, error)
+	// Groups the short urls matching the filters by an utm parameter, with
+	// their clicks.
+	GroupStats(context.Context, *GroupStatsRequest) (*GroupStatsReply, error)
+	// Renders the short url as a QR code.
+	QRCode(context.Context, *QRCodeRequest) (*QRCodeReply, error)
+	// Issues an API key.
+	IssueKey(context.Context, *IssueKeyRequest) (*KeyReply, error)
+	// Revokes an API key.
+	RevokeKey(context.Context, *RevokeKeyRequest) (*KeyReply, error)
+}
+
+// UnimplementedShortURLServer should be embedded to have forward compatible implementations.
+type UnimplementedShortURLServer struct {
+}
+
+func (UnimplementedShortURLServer) Create(context.Context, *CreateRequest) (*CreateReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedShortURLServer) CreateBatch(context.Context, *CreateBatchRequest) (*CreateBatchReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBatch not implemented")
+}
+func (UnimplementedShortURLServer) Query(context.Context, *QueryRequest) (*QueryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedShortURLServer) Update(context.Context, *UpdateRequest) (*UpdateReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedShortURLServer) Delete(context.Context, *QueryRequest) (*DeleteReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedShortURLServer) List(context.Context, *ListRequest) (*ListReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedShortURLServer) Clicks(context.Context, *QueryRequest) (*ClicksReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Clicks not implemented")
+}
+func (UnimplementedShortURLServer) Stats(context.Context, *QueryRequest) (*StatsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedShortURLServer) GroupStats(context.Context, *GroupStatsRequest) (*GroupStatsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GroupStats not implemented")
+}
+func (UnimplementedShortURLServer) QRCode(context.Context, *QRCodeRequest) (*QRCodeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QRCode not implemented")
+}
+func (UnimplementedShortURLServer) IssueKey(context.Context, *IssueKeyRequest) (*KeyReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IssueKey not implemented")
+}
+func (UnimplementedShortURLServer) RevokeKey(context.Context, *RevokeKeyRequest) (*KeyReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeKey not implemented")
+}
+
+// UnsafeShortURLServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ShortURLServer will
+// result in compilation errors.
+type UnsafeShortURLServer interface {
+	mustEmbedUnimplementedShortURLServer()
+}
+
+func RegisterShortURLServer(s grpc.ServiceRegistrar, srv ShortURLServer) {
+	s.RegisterService(&ShortURL_ServiceDesc, srv)
+}
+
+func _ShortURL_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_CreateBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).CreateBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/CreateBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).CreateBatch(ctx, req.(*CreateBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/Query",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).Delete(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_Clicks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).Clicks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/Clicks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).Clicks(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).Stats(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_GroupStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).GroupStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/GroupStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).GroupStats(ctx, req.(*GroupStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_QRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).QRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/QRCode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).QRCode(ctx, req.(*QRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_IssueKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IssueKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).IssueKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/IssueKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).IssueKey(ctx, req.(*IssueKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShortURL_RevokeKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortURLServer).RevokeKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.ShortURL/RevokeKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortURLServer).RevokeKey(ctx, req.(*RevokeKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ShortURL_ServiceDesc is the grpc.ServiceDesc for ShortURL service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ShortURL_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.ShortURL",
+	HandlerType: (*ShortURLServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _ShortURL_Create_Handler,
+		},
+		{
+			MethodName: "CreateBatch",
+			Handler:    _ShortURL_CreateBatch_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _ShortURL_Query_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _ShortURL_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _ShortURL_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _ShortURL_List_Handler,
+		},
+		{
+			MethodName: "Clicks",
+			Handler:    _ShortURL_Clicks_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _ShortURL_Stats_Handler,
+		},
+		{
+			MethodName: "GroupStats",
+			Handler:    _ShortURL_GroupStats_Handler,
+		},
+		{
+			MethodName: "QRCode",
+			Handler:    _ShortURL_QRCode_Handler,
+		},
+		{
+			MethodName: "IssueKey",
+			Handler:    _ShortURL_IssueKey_Handler,
+		},
+		{
+			MethodName: "RevokeKey",
+			Handler:    _ShortURL_RevokeKey_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "short_url.proto",
+}
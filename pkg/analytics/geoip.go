@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP looks up the country of IP.
+type GeoIP interface {
+	// Country returns the ISO 3166-1 code of the country, empty if unknown.
+	Country(ip string) string
+	Close() error
+}
+
+// NewGeoIP returns a GeoIP backed by the local MaxMind database at the path,
+// the country is always unknown if the path is empty.
+func NewGeoIP(fpath string) (GeoIP, error) {
+	if fpath == "" {
+		return nopGeoIP{}, nil
+	}
+
+	reader, err := geoip2.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	return &maxmindGeoIP{reader: reader}, nil
+}
+
+type maxmindGeoIP struct {
+	reader *geoip2.Reader
+}
+
+func (g *maxmindGeoIP) Country(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	record, err := g.reader.Country(parsed)
+	if err != nil {
+		return ""
+	}
+	return record.Country.IsoCode
+}
+
+func (g *maxmindGeoIP) Close() error {
+	return g.reader.Close()
+}
+
+type nopGeoIP struct{}
+
+func (nopGeoIP) Country(string) string { return "" }
+
+func (nopGeoIP) Close() error { return nil }
@@ -0,0 +1,191 @@
+package analytics
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+const (
+	// default of the analytics config
+	defaultBufferSize    = 10000
+	defaultFlushInterval = 5 * time.Second
+
+	// maxBatchSize is the max click events kept for a flush, the events beyond
+	// it are dropped if the storage keeps failing.
+	maxBatchSize = 100000
+)
+
+// queued is the click waiting in the queue, the slow work of it, looking up
+// the country and hashing the IP, is left to the pipeline.
+type queued struct {
+	time      time.Time
+	shortCode string
+	visitor   Visitor
+}
+
+// Pipeline records the clicks asynchronously. It aggregates the clicks into
+// the counter of every short code, and flushes the counters and the click
+// events to the storage periodically.
+type Pipeline struct {
+	dao      *dao.Dao
+	geoIP    GeoIP
+	ipSalt   string
+	interval time.Duration
+	logger   log.Logger
+
+	queue   chan queued
+	quit    chan struct{}
+	once    sync.Once
+	dropped int64
+}
+
+// NewPipeline returns a Pipeline, which should be run by Run.
+func NewPipeline(conf *config.Config, store dao.Storage, logger log.Logger) (*Pipeline, error) {
+	geoIP, err := NewGeoIP(conf.Analytics.GeoIPDB)
+	if err != nil {
+		return nil, err
+	}
+
+	bufferSize := conf.Analytics.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultBufferSize
+	}
+
+	interval := time.Duration(conf.Analytics.FlushInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+
+	// a known salt lets the hashes be reversed by hashing all of the IPs, so
+	// none is shipped, and a random one is used unless it is configured
+	ipSalt := conf.Analytics.IPSalt
+	if ipSalt == "" {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		ipSalt = hex.EncodeToString(salt)
+		logger.Warnw("no ip_salt configured, a random one is used, the visitors are counted again after restarts and across instances")
+	}
+
+	return &Pipeline{
+		dao:      dao.New(store),
+		geoIP:    geoIP,
+		ipSalt:   ipSalt,
+		interval: interval,
+		logger:   logger,
+		queue:    make(chan queued, bufferSize),
+		quit:     make(chan struct{}),
+	}, nil
+}
+
+// Record queues a click of the short code by the visitor in the context. It
+// never blocks, the click is dropped if the queue is full.
+func (p *Pipeline) Record(ctx context.Context, shortCode string) {
+	q := queued{
+		time:      time.Now(),
+		shortCode: shortCode,
+		visitor:   VisitorFromContext(ctx),
+	}
+
+	select {
+	case p.queue <- q:
+	default:
+		atomic.AddInt64(&p.dropped, 1)
+	}
+}
+
+// Run consumes the queue until Close, and flushes the rest before returning.
+func (p *Pipeline) Run() error {
+	defer p.geoIP.Close()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	b := &batch{counters: make(map[string]int64)}
+	for {
+		select {
+		case q := <-p.queue:
+			b.collect(p.click(q))
+		case <-ticker.C:
+			p.flush(b)
+		case <-p.quit:
+			for {
+				select {
+				case q := <-p.queue:
+					b.collect(p.click(q))
+				default:
+					p.flush(b)
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// Close stops the pipeline.
+func (p *Pipeline) Close() {
+	p.once.Do(func() {
+		close(p.quit)
+	})
+}
+
+func (p *Pipeline) click(q queued) dao.Click {
+	c := dao.Click{
+		Time:      q.time,
+		ShortCode: q.shortCode,
+		Referrer:  q.visitor.Referrer,
+		UserAgent: q.visitor.UserAgent,
+	}
+	c.Browser, c.Device = parseUserAgent(q.visitor.UserAgent)
+	if q.visitor.IP != "" {
+		c.Country = p.geoIP.Country(q.visitor.IP)
+		c.IPHash = fmt.Sprintf("%x", sha256.Sum256([]byte(p.ipSalt+q.visitor.IP)))
+	}
+	return c
+}
+
+// batch is the clicks collected between flushes.
+type batch struct {
+	counters map[string]int64
+	events   []dao.Click
+}
+
+func (b *batch) collect(c dao.Click) {
+	b.counters[c.ShortCode]++
+	if len(b.events) < maxBatchSize {
+		b.events = append(b.events, c)
+	}
+}
+
+// flush writes the batch to the storage, the failed parts are kept for the
+// next time.
+func (p *Pipeline) flush(b *batch) {
+	for shortCode, n := range b.counters {
+		if err := p.dao.IncrClicks(shortCode, n); err != nil {
+			p.logger.Errorw("flush clicks error", "shortURL", shortCode, "clicks", n, "err", err)
+			continue
+		}
+		delete(b.counters, shortCode)
+	}
+
+	if err := p.dao.AddClickEvents(b.events); err != nil {
+		p.logger.Errorw("flush click events error", "events", len(b.events), "err", err)
+	} else {
+		b.events = b.events[:0]
+	}
+
+	if dropped := atomic.SwapInt64(&p.dropped, 0); dropped > 0 {
+		p.logger.Warnw("clicks dropped, the queue is full", "dropped", dropped)
+	}
+}
@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"strings"
+)
+
+// devices of user agent
+const (
+	DeviceBot     = "bot"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// parseUserAgent returns the browser and the device of the user agent. It
+// only knows the common ones, which are enough for the stats.
+func parseUserAgent(ua string) (browser string, device string) {
+	if ua == "" {
+		return "", ""
+	}
+	lower := strings.ToLower(ua)
+
+	switch {
+	case strings.Contains(lower, "bot"), strings.Contains(lower, "spider"), strings.Contains(lower, "crawler"):
+		device = DeviceBot
+	case strings.Contains(lower, "ipad"), strings.Contains(lower, "tablet"):
+		device = DeviceTablet
+	case strings.Contains(lower, "mobi"), strings.Contains(lower, "iphone"), strings.Contains(lower, "android"):
+		device = DeviceMobile
+	default:
+		device = DeviceDesktop
+	}
+
+	// the order matters, e.g. Edge and Opera claim to be Chrome and Safari
+	switch {
+	case strings.Contains(ua, "Edg/"), strings.Contains(ua, "Edge/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"), strings.Contains(ua, "Opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "MSIE"), strings.Contains(ua, "Trident/"):
+		browser = "IE"
+	default:
+		browser = "Other"
+	}
+
+	return browser, device
+}
@@ -0,0 +1,26 @@
+package analytics
+
+import "context"
+
+// Visitor is who follows a short link, it is populated into the context by
+// the transport.
+type Visitor struct {
+	IP        string
+	Referrer  string
+	UserAgent string
+}
+
+type visitorKey struct{}
+
+var activeVisitorKey = visitorKey{}
+
+// ContextWithVisitor function
+func ContextWithVisitor(ctx context.Context, v Visitor) context.Context {
+	return context.WithValue(ctx, activeVisitorKey, v)
+}
+
+// VisitorFromContext function
+func VisitorFromContext(ctx context.Context) Visitor {
+	v, _ := ctx.Value(activeVisitorKey).(Visitor)
+	return v
+}
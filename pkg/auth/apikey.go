@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+const (
+	// keyPrefix tells the API keys from the other tokens, the key is
+	// keyPrefix + ID + "_" + secret.
+	keyPrefix = "sk_"
+
+	keyIDBytes     = 8
+	keySecretBytes = 24
+
+	// adminSubject is the subject of the bootstrap key
+	adminSubject = "admin"
+)
+
+// GenerateKey returns a new API key, its ID, and the hash of its secret which
+// is stored instead of the key.
+func GenerateKey() (id string, key string, hash string, err error) {
+	b := make([]byte, keyIDBytes+keySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+
+	id = hex.EncodeToString(b[:keyIDBytes])
+	secret := base64.RawURLEncoding.EncodeToString(b[keyIDBytes:])
+	return id, keyPrefix + id + "_" + secret, HashSecret(secret), nil
+}
+
+// HashSecret returns the sha256 of the secret in hex.
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsKey reports whether the token looks like an API key.
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, keyPrefix)
+}
+
+func parseKey(key string) (id string, secret string, ok bool) {
+	if !IsKey(key) {
+		return "", "", false
+	}
+	parts := strings.SplitN(key[len(keyPrefix):], "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// NewKeyAuthenticator returns an Authenticator of the API keys in the storage.
+// The bootstrap key of adminKeyHash has the admin scope, it is disabled if
+// adminKeyHash is empty.
+func NewKeyAuthenticator(store dao.Storage, adminKeyHash string) Authenticator {
+	return &keyAuthenticator{
+		dao:          dao.New(store),
+		adminKeyHash: strings.ToLower(adminKeyHash),
+	}
+}
+
+type keyAuthenticator struct {
+	dao          *dao.Dao
+	adminKeyHash string
+}
+
+func (a *keyAuthenticator) Authenticate(_ context.Context, token string) (*Principal, error) {
+	if a.adminKeyHash != "" && hashEqual(HashSecret(token), a.adminKeyHash) {
+		return &Principal{Subject: adminSubject, Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	id, secret, ok := parseKey(token)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	key, err := a.dao.GetAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt > 0 || !hashEqual(HashSecret(secret), key.Hash) {
+		return nil, ErrInvalidToken
+	}
+
+	return &Principal{Subject: "key:" + key.ID, Scopes: key.Scopes, Domain: key.Domain}, nil
+}
+
+func hashEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,91 @@
+// Package auth authenticates the callers of the admin API. The transports put
+// the token of the caller into the context, and the endpoint middleware
+// authenticates it into a Principal.
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// scopes of callers, a scope implies the ones below it
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var scopeLevels = map[string]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+var (
+	// ErrInvalidToken is returned when the token is unknown, malformed,
+	// revoked or expired.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// ValidScope reports whether the scope is known.
+func ValidScope(scope string) bool {
+	_, ok := scopeLevels[scope]
+	return ok
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	// Subject identifies the caller, it is recorded as the owner of the links
+	// created by the caller.
+	Subject string
+	Scopes  []string
+
+	// Domain is the short domain of the links created by the caller, the
+	// default one if empty.
+	Domain string
+}
+
+// Allows reports whether the principal has the scope, or a scope implying it.
+func (p *Principal) Allows(scope string) bool {
+	for _, s := range p.Scopes {
+		if scopeLevels[s] >= scopeLevels[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates the token of the caller.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+type contextKey int
+
+const (
+	tokenContextKey contextKey = iota
+	principalContextKey
+)
+
+// ContextWithToken returns a new Context that carries the token of the caller.
+func ContextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenContextKey, token)
+}
+
+// TokenFromContext returns the token of the caller, empty if absent.
+func TokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenContextKey).(string)
+	return token
+}
+
+// ContextWithPrincipal returns a new Context that carries the authenticated
+// caller.
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// PrincipalFromContext returns the authenticated caller, nil if absent.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalContextKey).(*Principal)
+	return p
+}
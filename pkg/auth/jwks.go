@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksTimeout is the timeout of fetching the JWKS URL
+	jwksTimeout = 10 * time.Second
+
+	// jwksMinRefresh limits the refreshes for the unknown key IDs
+	jwksMinRefresh = time.Minute
+)
+
+var (
+	// ErrUnknownKey is returned when no key of the key set verifies the token.
+	ErrUnknownKey = errors.New("unknown signing key")
+)
+
+// KeySource provides the public keys which the JWTs are verified with.
+type KeySource interface {
+	// Key returns the key of the key ID, kid may be empty if the token has
+	// none.
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// KeySet is a static set of public keys keyed by their key IDs, e.g. a local
+// key pair. A token without a key ID is verified with the only key of the set.
+type KeySet map[string]crypto.PublicKey
+
+// Key implements KeySource.
+func (s KeySet) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses the RSA and EC signing keys of the JSON Web Key Set. Other
+// keys are skipped.
+func ParseJWKS(data []byte) (KeySet, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+
+	set := KeySet{}
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			key, err = k.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %v", k.Kid, err)
+		}
+		set[k.Kid] = key
+	}
+	return set, nil
+}
+
+func (k *jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 2 || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA key")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+}
+
+func (k *jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("invalid EC key")
+	}
+	return key, nil
+}
+
+// NewJWKSSource returns a KeySource of the JWKS at the location, a local file
+// or an http(s) URL. The key set is loaded once here, and reloaded every
+// refresh interval, or when the token has an unknown key ID, so the rotated
+// keys are picked up. The last key set is kept if the reload fails.
+func NewJWKSSource(location string, refresh time.Duration) (KeySource, error) {
+	s := &jwksSource{
+		location: location,
+		refresh:  refresh,
+		client:   &http.Client{Timeout: jwksTimeout},
+	}
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	s.loadedAt = time.Now()
+	s.triedAt = s.loadedAt
+	return s, nil
+}
+
+type jwksSource struct {
+	location string
+	refresh  time.Duration
+	client   *http.Client
+
+	mtx  sync.Mutex
+	keys KeySet
+
+	// loadedAt is the time of the last successful load, triedAt is the time
+	// of the last load, successful or not, so a failing location is not
+	// hammered.
+	loadedAt time.Time
+	triedAt  time.Time
+
+	// loading is the load in flight, the callers share it.
+	loading *jwksLoad
+}
+
+// jwksLoad is a load of the key set, err is set before done is closed.
+type jwksLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// Key implements KeySource. The periodic reload runs in the background with
+// the last key set served meanwhile, only the tokens of unknown key IDs wait
+// for a reload, up to the deadline of ctx.
+func (s *jwksSource) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mtx.Lock()
+	keys := s.keys
+	if s.refresh > 0 && time.Since(s.loadedAt) >= s.refresh && time.Since(s.triedAt) >= s.retryIn() {
+		s.reload()
+	}
+	s.mtx.Unlock()
+
+	key, err := keys.Key(ctx, kid)
+	if err != ErrUnknownKey {
+		return key, err
+	}
+
+	s.mtx.Lock()
+	l := s.loading
+	if l == nil && time.Since(s.triedAt) >= jwksMinRefresh {
+		l = s.reload()
+	}
+	s.mtx.Unlock()
+	if l == nil {
+		return nil, err
+	}
+
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+	s.mtx.Lock()
+	keys = s.keys
+	s.mtx.Unlock()
+	return keys.Key(ctx, kid)
+}
+
+// retryIn returns how long a failed periodic reload waits to be retried.
+func (s *jwksSource) retryIn() time.Duration {
+	if s.refresh < jwksMinRefresh {
+		return s.refresh
+	}
+	return jwksMinRefresh
+}
+
+// reload starts a load unless one is in flight, and returns the load. It is
+// called with the lock held. The load is detached from the callers, so a
+// canceled request never fails the load shared by others.
+func (s *jwksSource) reload() *jwksLoad {
+	if s.loading != nil {
+		return s.loading
+	}
+	l := &jwksLoad{done: make(chan struct{})}
+	s.loading = l
+	s.triedAt = time.Now()
+
+	go func() {
+		keys, err := s.load()
+
+		s.mtx.Lock()
+		if err == nil {
+			s.keys = keys
+			s.loadedAt = time.Now()
+		}
+		s.loading = nil
+		s.mtx.Unlock()
+
+		l.err = err
+		close(l.done)
+	}()
+	return l
+}
+
+// load reads and parses the key set, the fetch is bounded by the timeout of
+// the client.
+func (s *jwksSource) load() (KeySet, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %s: %v", s.location, err)
+	}
+	return ParseJWKS(data)
+}
+
+func (s *jwksSource) read() ([]byte, error) {
+	if !strings.HasPrefix(s.location, "http://") && !strings.HasPrefix(s.location, "https://") {
+		return ioutil.ReadFile(s.location)
+	}
+
+	resp, err := s.client.Get(s.location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwtMethods are the accepted signing methods, only the asymmetric ones so
+// the public keys can never be used as HMAC secrets.
+var jwtMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// JWTOptions configures the validation of the JWTs.
+type JWTOptions struct {
+	// Issuer and Audience are checked if not empty.
+	Issuer   string
+	Audience string
+
+	// ClockSkew is tolerated in checking exp, nbf and iat.
+	ClockSkew time.Duration
+
+	// Scopes are granted to every valid token, in addition to the scopes of
+	// its scope or scp claim.
+	Scopes []string
+}
+
+// NewJWTAuthenticator returns an Authenticator of the JWTs signed by the keys
+// of the source. The subject of the token is the subject of the principal, it
+// is required.
+func NewJWTAuthenticator(keys KeySource, opts JWTOptions) Authenticator {
+	return &jwtAuthenticator{
+		keys:   keys,
+		opts:   opts,
+		parser: jwt.NewParser(jwt.WithValidMethods(jwtMethods), jwt.WithoutClaimsValidation()),
+	}
+}
+
+type jwtAuthenticator struct {
+	keys   KeySource
+	opts   JWTOptions
+	parser *jwt.Parser
+}
+
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope scopeClaim `json:"scope,omitempty"`
+	Scp   scopeClaim `json:"scp,omitempty"`
+}
+
+// scopeClaim is a space-separated string of OAuth 2.0, or an array of the
+// scopes.
+type scopeClaim []string
+
+func (s *scopeClaim) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = strings.Fields(str)
+		return nil
+	}
+	var arr []string
+	if err := json.Unmarshal(data, &arr); err != nil {
+		return err
+	}
+	*s = arr
+	return nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	claims := &jwtClaims{}
+	var keyErr error
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := a.keys.Key(ctx, kid)
+		if err != nil && !errors.Is(err, ErrUnknownKey) {
+			keyErr = err
+		}
+		return key, err
+	})
+	if keyErr != nil {
+		// the key source fails, e.g. the JWKS URL is unreachable
+		return nil, keyErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := a.validate(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	p := &Principal{Subject: claims.Subject}
+	for _, scopes := range [][]string{a.opts.Scopes, claims.Scope, claims.Scp} {
+		for _, scope := range scopes {
+			if ValidScope(scope) {
+				p.Scopes = append(p.Scopes, scope)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (a *jwtAuthenticator) validate(claims *jwtClaims, now time.Time) error {
+	skew := a.opts.ClockSkew
+	switch {
+	case claims.Subject == "":
+		return errors.New("subject is missing")
+	case claims.ExpiresAt == nil:
+		return errors.New("expiry is missing")
+	case !claims.VerifyExpiresAt(now.Add(-skew), true):
+		return errors.New("token is expired")
+	case !claims.VerifyNotBefore(now.Add(skew), false):
+		return errors.New("token is not valid yet")
+	case !claims.VerifyIssuedAt(now.Add(skew), false):
+		return errors.New("token is used before issued")
+	case a.opts.Issuer != "" && !claims.VerifyIssuer(a.opts.Issuer, true):
+		return errors.New("issuer is not accepted")
+	case a.opts.Audience != "" && !claims.VerifyAudience(a.opts.Audience, true):
+		return errors.New("audience is not accepted")
+	}
+	return nil
+}
+
+// IsJWT reports whether the token looks like a JWT, three dot-separated parts
+// of base64url.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && !strings.ContainsAny(token, " +/=")
+}
+
+// NewChainAuthenticator returns an Authenticator of both the API keys and the
+// JWTs. The tokens looking like JWTs go to jwtAuthn, others go to keyAuthn.
+// Either may be nil.
+func NewChainAuthenticator(keyAuthn Authenticator, jwtAuthn Authenticator) Authenticator {
+	return &chainAuthenticator{keys: keyAuthn, jwt: jwtAuthn}
+}
+
+type chainAuthenticator struct {
+	keys Authenticator
+	jwt  Authenticator
+}
+
+func (a *chainAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error) {
+	authn := a.keys
+	if !IsKey(token) && IsJWT(token) {
+		authn = a.jwt
+	}
+	if authn == nil {
+		return nil, ErrInvalidToken
+	}
+	return authn.Authenticate(ctx, token)
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type testKeys struct {
+	rsa  *rsa.PrivateKey
+	ec   *ecdsa.PrivateKey
+	jwks string
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": "%s", "e": "%s"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "%s", "y": "%s"},
+		{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"}
+	]}`, b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))))
+
+	return &testKeys{rsa: rsaKey, ec: ecKey, jwks: jwks}
+}
+
+func writeJWKS(t *testing.T, jwks string) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(jwks), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign signs the claims of a valid token, overridden by claims, the nil
+// values remove the claims.
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	c := jwt.MapClaims{
+		"sub":   "alice",
+		"iss":   "https://sso.example.com",
+		"aud":   "short-url",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read write",
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+
+	token := jwt.NewWithClaims(method, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	keys := newTestKeys(t)
+	source, err := NewJWKSSource(writeJWKS(t, keys.jwks), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authn := NewJWTAuthenticator(source, JWTOptions{
+		Issuer:    "https://sso.example.com",
+		Audience:  "short-url",
+		ClockSkew: time.Minute,
+	})
+
+	now := time.Now()
+	cases := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"rs256", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, nil), true},
+		{"es256", sign(t, jwt.SigningMethodES256, "ec", keys.ec, nil), true},
+		{"es256 audiences", sign(t, jwt.SigningMethodES256, "ec", keys.ec, jwt.MapClaims{"aud": []string{"other", "short-url"}}), true},
+		{"expired within skew", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"exp": now.Add(-30 * time.Second).Unix()}), true},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"exp": now.Add(-2 * time.Minute).Unix()}), false},
+		{"no expiry", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"exp": nil}), false},
+		{"not before within skew", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"nbf": now.Add(30 * time.Second).Unix()}), true},
+		{"not before", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"nbf": now.Add(2 * time.Minute).Unix()}), false},
+		{"issued in future", sign(t, jwt.SigningMethodES256, "ec", keys.ec, jwt.MapClaims{"iat": now.Add(2 * time.Minute).Unix()}), false},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"iss": "https://evil.example.com"}), false},
+		{"no issuer", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"iss": nil}), false},
+		{"wrong audience", sign(t, jwt.SigningMethodES256, "ec", keys.ec, jwt.MapClaims{"aud": "other"}), false},
+		{"no subject", sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, jwt.MapClaims{"sub": nil}), false},
+		{"key of other kid", sign(t, jwt.SigningMethodRS256, "ec", keys.rsa, nil), false},
+		{"hmac", sign(t, jwt.SigningMethodHS256, "hmac", []byte("secret"), nil), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p, err := authn.Authenticate(context.Background(), c.token)
+			if !c.valid {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("got %v, %v, want ErrInvalidToken", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Subject != "alice" || len(p.Scopes) != 2 {
+				t.Fatalf("got principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWKSSourceRotation(t *testing.T) {
+	keys := newTestKeys(t)
+	path := writeJWKS(t, `{"keys": []}`)
+	source, err := NewJWKSSource(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authn := NewJWTAuthenticator(source, JWTOptions{})
+	token := sign(t, jwt.SigningMethodRS256, "rsa", keys.rsa, nil)
+
+	// the unknown key IDs reload the key set at most once a minute
+	if err := ioutil.WriteFile(path, []byte(keys.jwks), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authn.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v, want ErrInvalidToken before the min refresh", err)
+	}
+
+	s := source.(*jwksSource)
+	s.mtx.Lock()
+	s.triedAt = time.Now().Add(-jwksMinRefresh)
+	s.mtx.Unlock()
+	if _, err := authn.Authenticate(context.Background(), token); err != nil {
+		t.Fatalf("got %v, want the rotated key", err)
+	}
+
+	// a failed reload keeps the key set and the time of the last load
+	loadedAt := s.loadedAt
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	s.mtx.Lock()
+	s.triedAt = time.Now().Add(-jwksMinRefresh)
+	s.mtx.Unlock()
+	other := sign(t, jwt.SigningMethodES256, "unknown", keys.ec, nil)
+	if _, err := authn.Authenticate(context.Background(), other); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("got %v, want the load error", err)
+	}
+	if _, err := authn.Authenticate(context.Background(), token); err != nil {
+		t.Fatalf("got %v, want the last key set", err)
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.loadedAt.Equal(loadedAt) {
+		t.Fatalf("loadedAt moved on a failed load")
+	}
+}
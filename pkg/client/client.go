@@ -0,0 +1,179 @@
+// Package client provides the Go client of the short-url service. The clients
+// implement service.Service, so they may be used in place of the service.
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/sd/lb"
+	kitot "github.com/go-kit/kit/tracing/opentracing"
+
+	"github.com/WiFeng/short-url/pkg/service"
+)
+
+const (
+	// default of the options
+	defaultTimeout      = 2 * time.Second
+	defaultRetryMax     = 3
+	defaultRetryTimeout = 5 * time.Second
+)
+
+// Option configures the client.
+type Option func(*options)
+
+type options struct {
+	timeout      time.Duration
+	retryMax     int
+	retryTimeout time.Duration
+	tracer       opentracing.Tracer
+	logger       kitlog.Logger
+	token        string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		timeout:      defaultTimeout,
+		retryMax:     defaultRetryMax,
+		retryTimeout: defaultRetryTimeout,
+		tracer:       opentracing.GlobalTracer(),
+		logger:       kitlog.NewNopLogger(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTimeout sets the timeout of every attempt, 2s by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithRetry sets the max attempts of a call and the timeout of all the
+// attempts, 3 and 5s by default. Only the failures of the transport and the
+// internal errors of the reads are retried, the writes are retried only if
+// the context is of ContextWithRetry.
+func WithRetry(max int, timeout time.Duration) Option {
+	return func(o *options) {
+		o.retryMax = max
+		o.retryTimeout = timeout
+	}
+}
+
+// WithTracer sets the tracer which the trace is propagated by, the global
+// tracer by default.
+func WithTracer(tracer opentracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogger sets the logger of the client, log.Logger of the project may be
+// used too.
+func WithLogger(logger kitlog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithToken sets the API key or the bearer token which the calls are
+// authenticated with.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// idempotent are the operations which are retried by default, a retried
+// write may be applied twice, e.g. a create whose response is lost.
+var idempotent = map[string]bool{
+	"Query":      true,
+	"List":       true,
+	"Clicks":     true,
+	"Stats":      true,
+	"GroupStats": true,
+	"QRCode":     true,
+}
+
+type retryKey struct{}
+
+// ContextWithRetry returns a new Context which opts the write of the call in
+// to the retries, for the writes which are safe to be applied twice, e.g. an
+// update to the same values.
+func ContextWithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+// wrap wires the tracing, timeout and retry into the endpoint.
+func (o *options) wrap(e kitendpoint.Endpoint, operationName string) kitendpoint.Endpoint {
+	e = timeoutMiddleware(o.timeout)(e)
+
+	balancer := lb.NewRoundRobin(sd.FixedEndpointer{e})
+	retry := lb.RetryWithCallback(o.retryTimeout, balancer, func(n int, err error) (bool, error) {
+		return n < o.retryMax && service.ErrorCode(err) == service.CodeInternal, nil
+	})
+	retry = unwrapRetryMiddleware(retry)
+
+	if idempotent[operationName] {
+		e = retry
+	} else {
+		e = optInRetry(e, retry)
+	}
+
+	e = kitot.TraceClient(o.tracer, operationName)(e)
+	return e
+}
+
+// optInRetry calls retry for the contexts of ContextWithRetry, and once for
+// others.
+func optInRetry(once kitendpoint.Endpoint, retry kitendpoint.Endpoint) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if opted, _ := ctx.Value(retryKey{}).(bool); opted {
+			return retry(ctx, request)
+		}
+		return once(ctx, request)
+	}
+}
+
+func timeoutMiddleware(timeout time.Duration) kitendpoint.Middleware {
+	return func(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, request)
+		}
+	}
+}
+
+// unwrapRetryMiddleware returns the final error of the retries, so callers
+// may compare it with the errors of service.
+func unwrapRetryMiddleware(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		if retryErr, ok := err.(lb.RetryError); ok {
+			err = retryErr.Final
+		}
+		return response, err
+	}
+}
+
+// str2err converts the error and its code of the response to an error of
+// service. The internal error is returned as a failure of the endpoint, so it
+// may be retried.
+func str2err(code string, msg string) (respErr error, err error) {
+	if msg == "" {
+		return nil, nil
+	}
+	if code == "" || code == service.CodeInternal {
+		return nil, service.NewError(service.CodeInternal, msg)
+	}
+	return service.NewError(code, msg), nil
+}
@@ -0,0 +1,572 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	kitendpoint "github.com/go-kit/kit/endpoint"
+	kitot "github.com/go-kit/kit/tracing/opentracing"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+
+	"github.com/WiFeng/short-url/pb"
+	"github.com/WiFeng/short-url/pkg/endpoint"
+	"github.com/WiFeng/short-url/pkg/service"
+)
+
+// NewGRPCClient returns a service backed by the gRPC server at the other end
+// of the conn. The caller is responsible for constructing the conn, and
+// eventually closing the underlying transport.
+func NewGRPCClient(conn *grpc.ClientConn, opts ...Option) service.Service {
+	o := newOptions(opts)
+	options := []kitgrpc.ClientOption{
+		kitgrpc.ClientBefore(kitot.ContextToGRPC(o.tracer, o.logger)),
+	}
+	if o.token != "" {
+		options = append(options, kitgrpc.ClientBefore(tokenToGRPC(o.token)))
+	}
+
+	var createEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"Create",
+		encodeGRPCCreateRequest,
+		decodeGRPCCreateResponse,
+		pb.CreateReply{},
+		options...,
+	).Endpoint()
+
+	var createBatchEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"CreateBatch",
+		encodeGRPCCreateBatchRequest,
+		decodeGRPCCreateBatchResponse,
+		pb.CreateBatchReply{},
+		options...,
+	).Endpoint()
+
+	var queryEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"Query",
+		encodeGRPCQueryRequest,
+		decodeGRPCQueryResponse,
+		pb.QueryReply{},
+		options...,
+	).Endpoint()
+
+	var updateEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"Update",
+		encodeGRPCUpdateRequest,
+		decodeGRPCUpdateResponse,
+		pb.UpdateReply{},
+		options...,
+	).Endpoint()
+
+	var deleteEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"Delete",
+		encodeGRPCQueryRequest,
+		decodeGRPCDeleteResponse,
+		pb.DeleteReply{},
+		options...,
+	).Endpoint()
+
+	var listEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"List",
+		encodeGRPCListRequest,
+		decodeGRPCListResponse,
+		pb.ListReply{},
+		options...,
+	).Endpoint()
+
+	var clicksEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"Clicks",
+		encodeGRPCQueryRequest,
+		decodeGRPCClicksResponse,
+		pb.ClicksReply{},
+		options...,
+	).Endpoint()
+
+	var statsEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"Stats",
+		encodeGRPCQueryRequest,
+		decodeGRPCStatsResponse,
+		pb.StatsReply{},
+		options...,
+	).Endpoint()
+
+	var groupStatsEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"GroupStats",
+		encodeGRPCGroupStatsRequest,
+		decodeGRPCGroupStatsResponse,
+		pb.GroupStatsReply{},
+		options...,
+	).Endpoint()
+
+	var qrCodeEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"QRCode",
+		encodeGRPCQRCodeRequest,
+		decodeGRPCQRCodeResponse,
+		pb.QRCodeReply{},
+		options...,
+	).Endpoint()
+
+	var issueKeyEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"IssueKey",
+		encodeGRPCIssueKeyRequest,
+		decodeGRPCKeyResponse,
+		pb.KeyReply{},
+		options...,
+	).Endpoint()
+
+	var revokeKeyEndpoint = kitgrpc.NewClient(
+		conn,
+		"pb.ShortURL",
+		"RevokeKey",
+		encodeGRPCRevokeKeyRequest,
+		decodeGRPCKeyResponse,
+		pb.KeyReply{},
+		options...,
+	).Endpoint()
+
+	return endpoint.Endpoints{
+		CreateEndpoint:      o.wrap(statusMiddleware(createEndpoint), "Create"),
+		CreateBatchEndpoint: o.wrap(statusMiddleware(createBatchEndpoint), "CreateBatch"),
+		QueryEndpoint:       o.wrap(statusMiddleware(queryEndpoint), "Query"),
+		UpdateEndpoint:      o.wrap(statusMiddleware(updateEndpoint), "Update"),
+		DeleteEndpoint:      o.wrap(statusMiddleware(deleteEndpoint), "Delete"),
+		ListEndpoint:        o.wrap(statusMiddleware(listEndpoint), "List"),
+		ClicksEndpoint:      o.wrap(statusMiddleware(clicksEndpoint), "Clicks"),
+		StatsEndpoint:       o.wrap(statusMiddleware(statsEndpoint), "Stats"),
+		GroupStatsEndpoint:  o.wrap(statusMiddleware(groupStatsEndpoint), "GroupStats"),
+		QRCodeEndpoint:      o.wrap(statusMiddleware(qrCodeEndpoint), "QRCode"),
+
+		IssueKeyEndpoint:  o.wrap(statusMiddleware(issueKeyEndpoint), "IssueKey"),
+		RevokeKeyEndpoint: o.wrap(statusMiddleware(revokeKeyEndpoint), "RevokeKey"),
+	}
+}
+
+// tokenToGRPC sets the token as the bearer token of the metadata.
+func tokenToGRPC(token string) kitgrpc.ClientRequestFunc {
+	return func(ctx context.Context, md *metadata.MD) context.Context {
+		(*md)["authorization"] = []string{"Bearer " + token}
+		return ctx
+	}
+}
+
+// statusMiddleware converts the gRPC status which carries the error code of
+// service back to the error of service, like the HTTP status is converted by
+// errorDecoder. Others are left as the failures of the transport.
+func statusMiddleware(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := next(ctx, request)
+		if err == nil {
+			return response, nil
+		}
+		st, ok := status.FromError(err)
+		if !ok {
+			return response, err
+		}
+		var svcErr error
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.ErrorInfo:
+				if d.Reason != "" && svcErr == nil {
+					svcErr = service.NewError(d.Reason, st.Message())
+				}
+			case *errdetails.RetryInfo:
+				svcErr = &endpoint.RateLimitError{RetryAfter: d.RetryDelay.AsDuration()}
+			}
+		}
+		if svcErr != nil {
+			return response, svcErr
+		}
+		return response, err
+	}
+}
+
+// encodeGRPCCreateRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain create request to a gRPC create request. Primarily useful in a
+// client.
+func encodeGRPCCreateRequest(_ context.Context, request interface{}) (interface{}, error) {
+	return createRequest2pb(request.(endpoint.CreateRequest)), nil
+}
+
+// encodeGRPCCreateBatchRequest is a transport/grpc.EncodeRequestFunc that
+// converts a user-domain batch create request to a gRPC batch create request.
+// Primarily useful in a client.
+func encodeGRPCCreateBatchRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.CreateBatchRequest)
+	r := &pb.CreateBatchRequest{Items: make([]*pb.CreateRequest, len(req.Items))}
+	for i, item := range req.Items {
+		r.Items[i] = createRequest2pb(item)
+	}
+	return r, nil
+}
+
+func createRequest2pb(req endpoint.CreateRequest) *pb.CreateRequest {
+	r := &pb.CreateRequest{
+		LongUrl:   req.LongURL,
+		Alias:     req.Alias,
+		Domain:    req.Domain,
+		ExpiresIn: req.ExpiresIn,
+		Redirect:  req.Redirect,
+		CreatedBy: req.CreatedBy,
+		Title:     req.Title,
+		Tags:      req.Tags,
+		Notes:     req.Notes,
+		Campaign:  req.Campaign,
+
+		UtmSource:   req.UTMSource,
+		UtmMedium:   req.UTMMedium,
+		UtmCampaign: req.UTMCampaign,
+		UtmTerm:     req.UTMTerm,
+		UtmContent:  req.UTMContent,
+	}
+	if req.ExpiresAt != nil {
+		r.ExpiresAt = timestamppb.New(*req.ExpiresAt)
+	}
+	return r
+}
+
+// encodeGRPCQueryRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain query request to a gRPC query request. Primarily useful in a
+// client.
+func encodeGRPCQueryRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.QueryRequest)
+	return &pb.QueryRequest{ShortUrl: req.ShortURL}, nil
+}
+
+// encodeGRPCUpdateRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain update request to a gRPC update request. Primarily useful in a
+// client.
+func encodeGRPCUpdateRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.UpdateRequest)
+	r := &pb.UpdateRequest{
+		ShortUrl: req.ShortURL,
+		LongUrl:  str2pb(req.LongURL),
+		Redirect: str2pb(req.Redirect),
+		Title:    str2pb(req.Title),
+		Notes:    str2pb(req.Notes),
+		Campaign: str2pb(req.Campaign),
+	}
+	if req.Tags != nil {
+		r.Tags = &pb.Tags{Values: *req.Tags}
+	}
+	if req.Disabled != nil {
+		r.Disabled = wrapperspb.Bool(*req.Disabled)
+	}
+	return r, nil
+}
+
+// encodeGRPCListRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain list request to a gRPC list request. Primarily useful in a
+// client.
+func encodeGRPCListRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.ListRequest)
+	r := &pb.ListRequest{
+		Tag:       req.Tag,
+		CreatedBy: req.CreatedBy,
+		LongUrl:   req.LongURL,
+		Domain:    req.Domain,
+		Sort:      req.Sort,
+		Order:     req.Order,
+		Cursor:    req.Cursor,
+		Limit:     int32(req.Limit),
+	}
+	if !req.CreatedAfter.IsZero() {
+		r.CreatedAfter = timestamppb.New(req.CreatedAfter)
+	}
+	if !req.CreatedBefore.IsZero() {
+		r.CreatedBefore = timestamppb.New(req.CreatedBefore)
+	}
+	return r, nil
+}
+
+// encodeGRPCGroupStatsRequest is a transport/grpc.EncodeRequestFunc that
+// converts a user-domain group stats request to a gRPC group stats request.
+// Primarily useful in a client.
+func encodeGRPCGroupStatsRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.GroupStatsRequest)
+	r := &pb.GroupStatsRequest{
+		GroupBy:   req.By,
+		Tag:       req.Tag,
+		CreatedBy: req.CreatedBy,
+		LongUrl:   req.LongURL,
+		Domain:    req.Domain,
+	}
+	if !req.CreatedAfter.IsZero() {
+		r.CreatedAfter = timestamppb.New(req.CreatedAfter)
+	}
+	if !req.CreatedBefore.IsZero() {
+		r.CreatedBefore = timestamppb.New(req.CreatedBefore)
+	}
+	return r, nil
+}
+
+// encodeGRPCQRCodeRequest is a transport/grpc.EncodeRequestFunc that converts a
+// user-domain qr code request to a gRPC qr code request. Primarily useful in a
+// client.
+func encodeGRPCQRCodeRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.QRCodeRequest)
+	r := &pb.QRCodeRequest{
+		ShortUrl:   req.ShortURL,
+		Format:     req.Format,
+		Size:       int32(req.Size),
+		Level:      req.Level,
+		Foreground: req.Foreground,
+		Background: req.Background,
+	}
+	if req.Margin != nil {
+		r.Margin = wrapperspb.Int32(int32(*req.Margin))
+	}
+	return r, nil
+}
+
+// encodeGRPCIssueKeyRequest is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain issue key request to a gRPC issue key request. Primarily useful
+// in a client.
+func encodeGRPCIssueKeyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.IssueKeyRequest)
+	return &pb.IssueKeyRequest{Name: req.Name, Scopes: req.Scopes, Domain: req.Domain}, nil
+}
+
+// encodeGRPCRevokeKeyRequest is a transport/grpc.EncodeRequestFunc that
+// converts a user-domain revoke key request to a gRPC revoke key request.
+// Primarily useful in a client.
+func encodeGRPCRevokeKeyRequest(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(endpoint.RevokeKeyRequest)
+	return &pb.RevokeKeyRequest{Id: req.ID}, nil
+}
+
+// decodeGRPCCreateResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC create reply to a user-domain create response. Primarily useful in a
+// client.
+func decodeGRPCCreateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.CreateReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	return endpoint.CreateResponse{ShortURL: reply.ShortUrl, Err: respErr}, err
+}
+
+// decodeGRPCCreateBatchResponse is a transport/grpc.DecodeResponseFunc that
+// converts a gRPC batch create reply to a user-domain batch create response.
+// Primarily useful in a client.
+func decodeGRPCCreateBatchResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.CreateBatchReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	resp := endpoint.CreateBatchResponse{
+		Results: make([]endpoint.CreateBatchResult, len(reply.Results)),
+		Err:     respErr,
+	}
+	for i, r := range reply.Results {
+		resp.Results[i] = endpoint.CreateBatchResult{ShortURL: r.ShortUrl, Error: r.Err, Code: r.Code}
+	}
+	return resp, err
+}
+
+// decodeGRPCQueryResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC query reply to a user-domain query response. Primarily useful in a
+// client.
+func decodeGRPCQueryResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.QueryReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	return endpoint.QueryResponse{Link: pb2link(reply.Link), Err: respErr}, err
+}
+
+// decodeGRPCUpdateResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC update reply to a user-domain update response. Primarily useful in a
+// client.
+func decodeGRPCUpdateResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.UpdateReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	return endpoint.UpdateResponse{Link: pb2link(reply.Link), Err: respErr}, err
+}
+
+// decodeGRPCListResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC list reply to a user-domain list response. Primarily useful in a
+// client.
+func decodeGRPCListResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ListReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	if respErr != nil || err != nil {
+		return endpoint.ListResponse{Err: respErr}, err
+	}
+
+	list := &service.LinkList{
+		Links:      make([]*service.ListItem, 0, len(reply.Links)),
+		NextCursor: reply.NextCursor,
+	}
+	for _, item := range reply.Links {
+		list.Links = append(list.Links, &service.ListItem{Link: pb2link(item.Link), Clicks: item.Clicks})
+	}
+	return endpoint.ListResponse{LinkList: list}, nil
+}
+
+// decodeGRPCDeleteResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC delete reply to a user-domain delete response. Primarily useful in a
+// client.
+func decodeGRPCDeleteResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.DeleteReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	return endpoint.DeleteResponse{Err: respErr}, err
+}
+
+// decodeGRPCClicksResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC clicks reply to a user-domain clicks response. Primarily useful in a
+// client.
+func decodeGRPCClicksResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.ClicksReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	return endpoint.ClicksResponse{Clicks: reply.Clicks, Err: respErr}, err
+}
+
+// decodeGRPCStatsResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC stats reply to a user-domain stats response. Primarily useful in a
+// client.
+func decodeGRPCStatsResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.StatsReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	if respErr != nil || err != nil {
+		return endpoint.StatsResponse{Err: respErr}, err
+	}
+	return endpoint.StatsResponse{Stats: &service.Stats{
+		Clicks:    reply.Clicks,
+		Visitors:  reply.Visitors,
+		Days:      pb2points(reply.Days),
+		Hours:     pb2points(reply.Hours),
+		Referrers: pb2counts(reply.Referrers),
+		Browsers:  pb2counts(reply.Browsers),
+		Devices:   pb2counts(reply.Devices),
+		Countries: pb2counts(reply.Countries),
+	}}, nil
+}
+
+// decodeGRPCGroupStatsResponse is a transport/grpc.DecodeResponseFunc that
+// converts a gRPC group stats reply to a user-domain group stats response.
+// Primarily useful in a client.
+func decodeGRPCGroupStatsResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.GroupStatsReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	if respErr != nil || err != nil {
+		return endpoint.GroupStatsResponse{Err: respErr}, err
+	}
+	groups := make([]*service.Group, 0, len(reply.Groups))
+	for _, g := range reply.Groups {
+		groups = append(groups, &service.Group{Name: g.Name, Links: g.Links, Clicks: g.Clicks})
+	}
+	return endpoint.GroupStatsResponse{Groups: groups}, nil
+}
+
+// decodeGRPCQRCodeResponse is a transport/grpc.DecodeResponseFunc that converts
+// a gRPC qr code reply to a user-domain qr code response. Primarily useful in a
+// client.
+func decodeGRPCQRCodeResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.QRCodeReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	if respErr != nil || err != nil {
+		return endpoint.QRCodeResponse{Err: respErr}, err
+	}
+	return endpoint.QRCodeResponse{QRCode: &service.QRCode{
+		ContentType: reply.ContentType,
+		Data:        reply.Data,
+	}}, nil
+}
+
+// decodeGRPCKeyResponse is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC key reply to a user-domain key response. Primarily useful in a client.
+func decodeGRPCKeyResponse(_ context.Context, grpcReply interface{}) (interface{}, error) {
+	reply := grpcReply.(*pb.KeyReply)
+	respErr, err := str2err(reply.Code, reply.Err)
+	if respErr != nil || err != nil {
+		return endpoint.KeyResponse{Err: respErr}, err
+	}
+	key := &service.APIKey{
+		ID:        reply.Id,
+		Key:       reply.Key,
+		Name:      reply.Name,
+		Scopes:    reply.Scopes,
+		Domain:    reply.Domain,
+		CreatedAt: reply.CreatedAt.AsTime(),
+		CreatedBy: reply.CreatedBy,
+	}
+	if reply.RevokedAt != nil {
+		t := reply.RevokedAt.AsTime()
+		key.RevokedAt = &t
+	}
+	return endpoint.KeyResponse{APIKey: key}, nil
+}
+
+func pb2points(points []*pb.Point) []service.Point {
+	res := make([]service.Point, 0, len(points))
+	for _, p := range points {
+		res = append(res, service.Point{Time: p.Time, Clicks: p.Clicks})
+	}
+	return res
+}
+
+func pb2counts(counts []*pb.Count) []service.Count {
+	res := make([]service.Count, 0, len(counts))
+	for _, c := range counts {
+		res = append(res, service.Count{Name: c.Name, Clicks: c.Clicks})
+	}
+	return res
+}
+
+func pb2link(l *pb.Link) *service.Link {
+	if l == nil {
+		return nil
+	}
+	link := &service.Link{
+		ShortURL:  l.ShortUrl,
+		LongURL:   l.LongUrl,
+		CreatedBy: l.CreatedBy,
+		Title:     l.Title,
+		Tags:      l.Tags,
+		Notes:     l.Notes,
+		Campaign:  l.Campaign,
+		Disabled:  l.Disabled,
+		Blocked:   l.Blocked,
+		Redirect:  l.Redirect,
+	}
+	if u := l.Utm; u != nil {
+		link.UTM = &service.UTM{Source: u.Source, Medium: u.Medium, Campaign: u.Campaign, Term: u.Term, Content: u.Content}
+	}
+	if l.ExpiresAt != nil {
+		t := l.ExpiresAt.AsTime()
+		link.ExpiresAt = &t
+	}
+	if l.CreatedAt != nil {
+		t := l.CreatedAt.AsTime()
+		link.CreatedAt = &t
+	}
+	return link
+}
+
+func str2pb(s *string) *wrapperspb.StringValue {
+	if s == nil {
+		return nil
+	}
+	return wrapperspb.String(*s)
+}
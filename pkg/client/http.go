@@ -0,0 +1,450 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	kitot "github.com/go-kit/kit/tracing/opentracing"
+	kithttp "github.com/go-kit/kit/transport/http"
+
+	"github.com/WiFeng/short-url/pkg/endpoint"
+	"github.com/WiFeng/short-url/pkg/service"
+)
+
+// NewHTTPClient returns a service backed by the HTTP server living at the
+// remote instance, e.g. "127.0.0.1:8081" or "http://127.0.0.1:8081".
+func NewHTTPClient(instance string, opts ...Option) (service.Service, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	u, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	options := []kithttp.ClientOption{
+		kithttp.ClientBefore(kitot.ContextToHTTP(o.tracer, o.logger)),
+	}
+	if o.token != "" {
+		options = append(options, kithttp.ClientBefore(tokenToHTTP(o.token)))
+	}
+
+	var createEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/create"),
+		encodeHTTPGenericRequest,
+		decodeHTTPCreateResponse,
+		options...,
+	).Endpoint()
+
+	var createBatchEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/create/batch"),
+		encodeHTTPGenericRequest,
+		decodeHTTPCreateBatchResponse,
+		options...,
+	).Endpoint()
+
+	var queryEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/query"),
+		encodeHTTPGenericRequest,
+		decodeHTTPQueryResponse,
+		options...,
+	).Endpoint()
+
+	var updateEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/update"),
+		encodeHTTPGenericRequest,
+		decodeHTTPUpdateResponse,
+		options...,
+	).Endpoint()
+
+	var deleteEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/delete"),
+		encodeHTTPGenericRequest,
+		decodeHTTPDeleteResponse,
+		options...,
+	).Endpoint()
+
+	var listEndpoint = kithttp.NewClient(
+		"GET",
+		copyURL(u, "/admin/links"),
+		encodeHTTPListRequest,
+		decodeHTTPListResponse,
+		options...,
+	).Endpoint()
+
+	var clicksEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/clicks"),
+		encodeHTTPGenericRequest,
+		decodeHTTPClicksResponse,
+		options...,
+	).Endpoint()
+
+	var statsEndpoint = kithttp.NewClient(
+		"GET",
+		copyURL(u, "/admin/stats/"),
+		encodeHTTPStatsRequest,
+		decodeHTTPStatsResponse,
+		options...,
+	).Endpoint()
+
+	var groupStatsEndpoint = kithttp.NewClient(
+		"GET",
+		copyURL(u, "/admin/stats"),
+		encodeHTTPGroupStatsRequest,
+		decodeHTTPGroupStatsResponse,
+		options...,
+	).Endpoint()
+
+	var qrCodeEndpoint = kithttp.NewClient(
+		"GET",
+		copyURL(u, "/admin/qr/"),
+		encodeHTTPQRCodeRequest,
+		decodeHTTPQRCodeResponse,
+		options...,
+	).Endpoint()
+
+	var issueKeyEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/keys/issue"),
+		encodeHTTPGenericRequest,
+		decodeHTTPKeyResponse,
+		options...,
+	).Endpoint()
+
+	var revokeKeyEndpoint = kithttp.NewClient(
+		"POST",
+		copyURL(u, "/admin/keys/revoke"),
+		encodeHTTPGenericRequest,
+		decodeHTTPKeyResponse,
+		options...,
+	).Endpoint()
+
+	// Returning the endpoint.Endpoints as a service.Service relies on the
+	// endpoint.Endpoints implementing the Service methods. That's just a simple bit
+	// of glue code.
+	return endpoint.Endpoints{
+		CreateEndpoint:      o.wrap(createEndpoint, "Create"),
+		CreateBatchEndpoint: o.wrap(createBatchEndpoint, "CreateBatch"),
+		QueryEndpoint:       o.wrap(queryEndpoint, "Query"),
+		UpdateEndpoint:      o.wrap(updateEndpoint, "Update"),
+		DeleteEndpoint:      o.wrap(deleteEndpoint, "Delete"),
+		ListEndpoint:        o.wrap(listEndpoint, "List"),
+		ClicksEndpoint:      o.wrap(clicksEndpoint, "Clicks"),
+		StatsEndpoint:       o.wrap(statsEndpoint, "Stats"),
+		GroupStatsEndpoint:  o.wrap(groupStatsEndpoint, "GroupStats"),
+		QRCodeEndpoint:      o.wrap(qrCodeEndpoint, "QRCode"),
+
+		IssueKeyEndpoint:  o.wrap(issueKeyEndpoint, "IssueKey"),
+		RevokeKeyEndpoint: o.wrap(revokeKeyEndpoint, "RevokeKey"),
+	}, nil
+}
+
+// tokenToHTTP sets the token as the bearer token of the request.
+func tokenToHTTP(token string) kithttp.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return ctx
+	}
+}
+
+func copyURL(base *url.URL, path string) *url.URL {
+	next := *base
+	next.Path = path
+	return &next
+}
+
+// encodeHTTPGenericRequest is a transport/http.EncodeRequestFunc that
+// JSON-encodes any request to the request body. Primarily useful in a client.
+func encodeHTTPGenericRequest(_ context.Context, r *http.Request, request interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Body = ioutil.NopCloser(&buf)
+	return nil
+}
+
+// encodeHTTPStatsRequest is a transport/http.EncodeRequestFunc that puts the
+// short code of the request into the path, and its short domain into the query
+// string. Primarily useful in a client.
+func encodeHTTPStatsRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoint.QueryRequest)
+	code := req.ShortURL
+	if i := strings.LastIndex(code, "/"); i >= 0 {
+		r.URL.RawQuery = url.Values{"short_domain": {code[:i]}}.Encode()
+		code = code[i+1:]
+	}
+	r.URL.Path += url.PathEscape(code)
+	return nil
+}
+
+// encodeHTTPListRequest is a transport/http.EncodeRequestFunc that puts the
+// list request into the query string. Primarily useful in a client.
+func encodeHTTPListRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoint.ListRequest)
+	q := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("tag", req.Tag)
+	set("created_by", req.CreatedBy)
+	set("long_url", req.LongURL)
+	set("domain", req.Domain)
+	set("sort", req.Sort)
+	set("order", req.Order)
+	set("cursor", req.Cursor)
+	if !req.CreatedAfter.IsZero() {
+		q.Set("created_after", req.CreatedAfter.Format(time.RFC3339))
+	}
+	if !req.CreatedBefore.IsZero() {
+		q.Set("created_before", req.CreatedBefore.Format(time.RFC3339))
+	}
+	if req.Limit != 0 {
+		q.Set("limit", strconv.Itoa(req.Limit))
+	}
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+// encodeHTTPGroupStatsRequest is a transport/http.EncodeRequestFunc that puts
+// the group stats request into the query string, the filters are encoded like
+// the list request. Primarily useful in a client.
+func encodeHTTPGroupStatsRequest(ctx context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoint.GroupStatsRequest)
+	err := encodeHTTPListRequest(ctx, r, endpoint.ListRequest{
+		Tag:           req.Tag,
+		CreatedBy:     req.CreatedBy,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		LongURL:       req.LongURL,
+		Domain:        req.Domain,
+	})
+	if err != nil {
+		return err
+	}
+	if req.By != "" {
+		q := r.URL.Query()
+		q.Set("group_by", req.By)
+		r.URL.RawQuery = q.Encode()
+	}
+	return nil
+}
+
+// encodeHTTPQRCodeRequest is a transport/http.EncodeRequestFunc that puts the
+// code of the short URL into the path, and its short domain and the options
+// of the image into the query string. Primarily useful in a client.
+func encodeHTTPQRCodeRequest(_ context.Context, r *http.Request, request interface{}) error {
+	req := request.(endpoint.QRCodeRequest)
+	q := url.Values{}
+	set := func(key string, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	code := req.ShortURL
+	if i := strings.LastIndex(code, "/"); i >= 0 {
+		set("short_domain", code[:i])
+		code = code[i+1:]
+	}
+	set("format", req.Format)
+	set("level", req.Level)
+	set("foreground", req.Foreground)
+	set("background", req.Background)
+	if req.Size != 0 {
+		q.Set("size", strconv.Itoa(req.Size))
+	}
+	if req.Margin != nil {
+		q.Set("margin", strconv.Itoa(*req.Margin))
+	}
+	r.URL.Path += url.PathEscape(code)
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+// decodeHTTPCreateResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded create response from the HTTP response body. If the response has
+// a non-200 status code, we will interpret that as an error and attempt to
+// decode the specific error message from the response body. Primarily useful in
+// a client.
+func decodeHTTPCreateResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.CreateResponse{Err: respErr}, err
+	}
+	var resp endpoint.CreateResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPCreateBatchResponse is a transport/http.DecodeResponseFunc that
+// decodes a JSON-encoded batch create response from the HTTP response body.
+// Primarily useful in a client.
+func decodeHTTPCreateBatchResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.CreateBatchResponse{Err: respErr}, err
+	}
+	var resp endpoint.CreateBatchResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPQueryResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded query response from the HTTP response body. If the response has
+// a non-200 status code, we will interpret that as an error and attempt to
+// decode the specific error message from the response body. Primarily useful in
+// a client.
+func decodeHTTPQueryResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.QueryResponse{Err: respErr}, err
+	}
+	resp := endpoint.QueryResponse{Link: &service.Link{}}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPUpdateResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded update response from the HTTP response body. Primarily useful in
+// a client.
+func decodeHTTPUpdateResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.UpdateResponse{Err: respErr}, err
+	}
+	resp := endpoint.UpdateResponse{Link: &service.Link{}}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPDeleteResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded delete response from the HTTP response body. Primarily useful in
+// a client.
+func decodeHTTPDeleteResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.DeleteResponse{Err: respErr}, err
+	}
+	var resp endpoint.DeleteResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPListResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded list response from the HTTP response body. Primarily useful in
+// a client.
+func decodeHTTPListResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.ListResponse{Err: respErr}, err
+	}
+	resp := endpoint.ListResponse{LinkList: &service.LinkList{}}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPClicksResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded clicks response from the HTTP response body. Primarily useful in
+// a client.
+func decodeHTTPClicksResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.ClicksResponse{Err: respErr}, err
+	}
+	var resp endpoint.ClicksResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPStatsResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded stats response from the HTTP response body. Primarily useful in
+// a client.
+func decodeHTTPStatsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.StatsResponse{Err: respErr}, err
+	}
+	resp := endpoint.StatsResponse{Stats: &service.Stats{}}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPGroupStatsResponse is a transport/http.DecodeResponseFunc that
+// decodes a JSON-encoded group stats response from the HTTP response body.
+// Primarily useful in a client.
+func decodeHTTPGroupStatsResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.GroupStatsResponse{Err: respErr}, err
+	}
+	var resp endpoint.GroupStatsResponse
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// decodeHTTPQRCodeResponse is a transport/http.DecodeResponseFunc that reads
+// the image of the qr code from the HTTP response body. Primarily useful in a
+// client.
+func decodeHTTPQRCodeResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.QRCodeResponse{Err: respErr}, err
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return endpoint.QRCodeResponse{QRCode: &service.QRCode{
+		ContentType: r.Header.Get("Content-Type"),
+		Data:        data,
+	}}, nil
+}
+
+// decodeHTTPKeyResponse is a transport/http.DecodeResponseFunc that decodes a
+// JSON-encoded key response from the HTTP response body. Primarily useful in a
+// client.
+func decodeHTTPKeyResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		respErr, err := errorDecoder(r)
+		return endpoint.KeyResponse{Err: respErr}, err
+	}
+	resp := endpoint.KeyResponse{APIKey: &service.APIKey{}}
+	err := json.NewDecoder(r.Body).Decode(&resp)
+	return resp, err
+}
+
+// errorDecoder decodes the error of the response, the error of service goes to
+// the response and others fail the endpoint.
+func errorDecoder(r *http.Response) (respErr error, err error) {
+	var w errorWrapper
+	if err := json.NewDecoder(r.Body).Decode(&w); err != nil {
+		return nil, service.NewError(service.CodeInternal, r.Status)
+	}
+	if w.Code == service.CodeRateLimited {
+		seconds, _ := strconv.Atoi(r.Header.Get("Retry-After"))
+		return &endpoint.RateLimitError{RetryAfter: time.Duration(seconds) * time.Second}, nil
+	}
+	return str2err(w.Code, w.Error)
+}
+
+type errorWrapper struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
@@ -0,0 +1,20 @@
+package config
+
+// Analytics analytics config
+type Analytics struct {
+	// BufferSize is the capacity of the click queue, the clicks beyond it are
+	// dropped rather than delaying the redirect.
+	BufferSize int `toml:"buffer_size"`
+
+	// FlushInterval is in seconds.
+	FlushInterval int `toml:"flush_interval"`
+
+	// GeoIPDB is the path of a local MaxMind country database, the country is
+	// unknown if empty.
+	GeoIPDB string `toml:"geoip_db"`
+
+	// IPSalt is hashed with the IP of visitors, the raw IP is never stored.
+	// It is a secret shared by the instances, a random one of the process is
+	// used if empty.
+	IPSalt string `toml:"ip_salt"`
+}
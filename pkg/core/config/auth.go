@@ -0,0 +1,35 @@
+package config
+
+// Auth auth config
+type Auth struct {
+	// Enabled requires the admin API to be called with a key, the redirects
+	// are always open.
+	Enabled bool
+
+	// AdminKeyHash is the sha256 in hex of the bootstrap key, which has the
+	// admin scope and is used to issue the other keys.
+	AdminKeyHash string `toml:"admin_key_hash"`
+
+	JWT JWT
+}
+
+// JWT jwt config of the bearer tokens issued by SSO
+type JWT struct {
+	// JWKS is the path or the http(s) URL of the JSON Web Key Set which the
+	// tokens are verified with, the JWTs are not accepted if empty.
+	JWKS string
+
+	// Issuer and Audience are checked if not empty.
+	Issuer   string
+	Audience string
+
+	// ClockSkew is in seconds.
+	ClockSkew int `toml:"clock_skew"`
+
+	// RefreshInterval is in seconds, the JWKS is reloaded in it.
+	RefreshInterval int `toml:"refresh_interval"`
+
+	// Scopes are granted to every valid token, in addition to the scopes of
+	// its scope claim.
+	Scopes []string
+}
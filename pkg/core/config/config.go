@@ -2,22 +2,52 @@ package config
 
 // Config config
 type Config struct {
-	Server  Server
-	Redis   Redis
-	Mysql   Mysql
-	General General
+	Server    Server
+	Redis     Redis
+	Mysql     Mysql
+	Storage   Storage
+	Analytics Analytics
+	Auth      Auth
+	RateLimit RateLimit
+	Filter    Filter
+	Redirect  Redirect
+	QR        QR
+	General   General
+
+	// UTM are the default utm parameters of the long URLs by the hosts of the
+	// short domains, "*" is of the domains without their own.
+	UTM map[string]UTM
 }
 
 // Server server config
 type Server struct {
 	Name string
 	HTTP HTTP
+	GRPC GRPC
 	Log  zapLog
 }
 
 // HTTP http config
 type HTTP struct {
 	Addr string
+
+	// NotFoundPage is the html file shown to browsers for unknown links,
+	// a built-in page is used if empty.
+	NotFoundPage string `toml:"not_found_page"`
+
+	// BlockedPage is the html file of the warning shown to browsers for the
+	// links to blocked destinations, a built-in page is used if empty.
+	BlockedPage string `toml:"blocked_page"`
+
+	// TrustedProxies are the IPs or CIDRs of the proxies in front, whose
+	// X-Forwarded-For and X-Real-IP headers name the clients. The headers of
+	// the other peers are ignored.
+	TrustedProxies []string `toml:"trusted_proxies"`
+}
+
+// GRPC grpc config
+type GRPC struct {
+	Addr string
 }
 
 // Redis redis config
@@ -30,4 +60,17 @@ type Redis struct {
 
 // Mysql mysql config
 type Mysql struct {
+	Host         string
+	Port         int
+	User         string
+	Password     string
+	Database     string
+	MaxOpenConns int `toml:"max_open_conns"`
+	MaxIdleConns int `toml:"max_idle_conns"`
+}
+
+// Storage storage config
+type Storage struct {
+	Driver string
+	Cache  string
 }
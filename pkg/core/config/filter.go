@@ -0,0 +1,19 @@
+package config
+
+// Filter filter config of the destinations of links
+type Filter struct {
+	// Blocklist of the domains, their subdomains are blocked too.
+	Blocklist []string
+
+	// Allowlist of the domains, only them and their subdomains are allowed if
+	// it is not empty.
+	Allowlist []string
+
+	// Rules are the regexps of the blocked URLs, matched against the
+	// normalized URL.
+	Rules []string
+
+	// HashPrefixFile is the path of a local list of the sha256 prefixes in hex
+	// of the unsafe URL expressions, in the manner of Safe Browsing.
+	HashPrefixFile string `toml:"hash_prefix_file"`
+}
@@ -3,4 +3,25 @@ package config
 // General general config
 type General struct {
 	ShortDomain string `toml:"short_domain"`
+
+	// Domains are the short domains besides ShortDomain, e.g.
+	// "https://go.brand.com/". Each of them has its own codes.
+	Domains []string `toml:"domains"`
+
+	// AllowedSchemes of the long URL, http and https if empty.
+	AllowedSchemes []string `toml:"allowed_schemes"`
+
+	// MaxURLLength of the long URL, 2048 if zero.
+	MaxURLLength int `toml:"max_url_length"`
+
+	// MaxBatchSize is the max items of a batch create, 1000 if zero.
+	MaxBatchSize int `toml:"max_batch_size"`
+
+	// RedirectPrefix is the path of the redirects before the codes, e.g. "/s"
+	// serves "http://sh.url/s/2bI". The redirects are at the root if empty.
+	RedirectPrefix string `toml:"redirect_prefix"`
+
+	// ReservedWords can't be the codes, besides the built-in ones like admin
+	// and debug, so the codes never shadow the other paths.
+	ReservedWords []string `toml:"reserved_words"`
 }
@@ -0,0 +1,23 @@
+package config
+
+// QR qr code config of the short links
+type QR struct {
+	// Size is the default width and height of the images in pixels, 256 if
+	// zero.
+	Size int
+
+	// MaxSize is the largest size a request may ask for, 2048 if zero.
+	MaxSize int `toml:"max_size"`
+
+	// Level is the default error correction level, one of L, M, Q and H. M if
+	// empty.
+	Level string
+
+	// Margin is the default quiet zone in modules, 4 if nil.
+	Margin *int
+
+	// Foreground and Background are the default colors in #rgb or #rrggbb,
+	// black on white if empty.
+	Foreground string
+	Background string
+}
@@ -0,0 +1,26 @@
+package config
+
+// RateLimit ratelimit config
+type RateLimit struct {
+	Enabled bool
+
+	// Backend keeps the buckets, redis (default) shared by the instances, or
+	// memory in each process.
+	Backend string
+
+	Create   Rate
+	Redirect Rate
+
+	// Batch limits the batch creates, a batch takes a token however many
+	// items it has.
+	Batch Rate
+}
+
+// Rate rate config of a token bucket, zero is unlimited
+type Rate struct {
+	// Rate is the tokens refilled per second.
+	Rate float64
+
+	// Burst is the capacity of the bucket.
+	Burst int
+}
@@ -0,0 +1,24 @@
+package config
+
+// Redirect redirect config of the links
+type Redirect struct {
+	// Type of the redirects of the links without their own, one of 301, 302,
+	// 307, 308 and interstitial. 302 if empty.
+	Type string
+
+	// InterstitialPage is the html/template file of the interstitial page,
+	// which redirects by meta refresh and javascript. A built-in page is used
+	// if empty.
+	InterstitialPage string `toml:"interstitial_page"`
+
+	// InterstitialDelay is the seconds the interstitial page is shown for.
+	InterstitialDelay int `toml:"interstitial_delay"`
+
+	// CacheControl of the redirects, e.g. "private, max-age=90". It is not
+	// sent if empty.
+	CacheControl string `toml:"cache_control"`
+
+	// ForwardQuery merges the query string of the short URL onto the long URL,
+	// the parameters of the long URL are kept.
+	ForwardQuery bool `toml:"forward_query"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// UTM utm parameters of the long URLs
+type UTM struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Term     string
+	Content  string
+}
@@ -92,6 +92,12 @@ func Infow(ctx context.Context, msg string, keysAndValues ...interface{}) {
 	logg.Infow(msg, keysAndValues...)
 }
 
+// Warnw function
+func Warnw(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	logg := LoggerFromContext(ctx)
+	logg.Warnw(msg, keysAndValues...)
+}
+
 // With function
 func With(ctx context.Context, args ...interface{}) Logger {
 	logg := LoggerFromContext(ctx)
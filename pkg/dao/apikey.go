@@ -0,0 +1,38 @@
+package dao
+
+import (
+	"encoding/json"
+)
+
+// APIKey is the record of an API key. Only the hash of its secret is stored.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Hash   string   `json:"hash"`
+	Name   string   `json:"name,omitempty"`
+	Scopes []string `json:"scopes"`
+
+	// Domain is the short domain of the links created by the key, the default
+	// one if empty.
+	Domain string `json:"domain,omitempty"`
+
+	// CreatedAt and RevokedAt are unix time, RevokedAt is 0 if not revoked.
+	CreatedAt int64  `json:"created_at"`
+	CreatedBy string `json:"created_by,omitempty"`
+	RevokedAt int64  `json:"revoked_at,omitempty"`
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it is used by redis.
+func (k *APIKey) MarshalBinary() ([]byte, error) {
+	return json.Marshal(k)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it is used by redis.
+func (k *APIKey) UnmarshalBinary(data []byte) error {
+	return json.Unmarshal(data, k)
+}
+
+func (k *APIKey) clone() *APIKey {
+	c := *k
+	c.Scopes = append([]string(nil), k.Scopes...)
+	return &c
+}
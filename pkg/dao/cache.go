@@ -0,0 +1,236 @@
+package dao
+
+// Cache is the Storage of a cache, the links in it may be dropped.
+type Cache interface {
+	Storage
+	DelLink(idKey string) error
+}
+
+// NewCacheStorage returns a Storage that reads through the cache and writes
+// through to the backend. The backend is the source of truth, so errors of
+// the cache are ignored.
+func NewCacheStorage(cache Cache, backend Storage) Storage {
+	return &cacheStorage{
+		cache:   cache,
+		backend: backend,
+	}
+}
+
+type cacheStorage struct {
+	cache   Cache
+	backend Storage
+}
+
+func (c *cacheStorage) GenerateID() (int64, error) {
+	return c.backend.GenerateID()
+}
+
+func (c *cacheStorage) GenerateIDs(n int) ([]int64, error) {
+	return c.backend.GenerateIDs(n)
+}
+
+func (c *cacheStorage) GetLink(idKey string) (*Link, error) {
+	if link, err := c.cache.GetLink(idKey); err == nil && link != nil {
+		return link, nil
+	}
+
+	link, err := c.backend.GetLink(idKey)
+	if err != nil || link == nil {
+		return link, err
+	}
+
+	c.cache.SetLink(idKey, link)
+	return link, nil
+}
+
+func (c *cacheStorage) GetShortURL(idKey string) (string, error) {
+	if val, err := c.cache.GetShortURL(idKey); err == nil && val != "" {
+		return val, nil
+	}
+
+	val, err := c.backend.GetShortURL(idKey)
+	if err != nil || val == "" {
+		return val, err
+	}
+
+	c.cache.SetShortURL(idKey, val)
+	return val, nil
+}
+
+// GetLinks reads the misses of the cache from the backend.
+func (c *cacheStorage) GetLinks(idKeys []string) ([]*Link, error) {
+	links, err := c.cache.GetLinks(idKeys)
+	if err != nil || len(links) != len(idKeys) {
+		links = make([]*Link, len(idKeys))
+	}
+
+	var missIdx []int
+	var missKeys []string
+	for i, link := range links {
+		if link == nil {
+			missIdx = append(missIdx, i)
+			missKeys = append(missKeys, idKeys[i])
+		}
+	}
+	if len(missKeys) == 0 {
+		return links, nil
+	}
+
+	found, err := c.backend.GetLinks(missKeys)
+	if err != nil {
+		return nil, err
+	}
+	for j, link := range found {
+		if link == nil {
+			continue
+		}
+		links[missIdx[j]] = link
+		c.cache.SetLink(missKeys[j], link)
+	}
+	return links, nil
+}
+
+// GetShortURLs reads the misses of the cache from the backend.
+func (c *cacheStorage) GetShortURLs(idKeys []string) ([]string, error) {
+	vals, err := c.cache.GetShortURLs(idKeys)
+	if err != nil || len(vals) != len(idKeys) {
+		vals = make([]string, len(idKeys))
+	}
+
+	var missIdx []int
+	var missKeys []string
+	for i, val := range vals {
+		if val == "" {
+			missIdx = append(missIdx, i)
+			missKeys = append(missKeys, idKeys[i])
+		}
+	}
+	if len(missKeys) == 0 {
+		return vals, nil
+	}
+
+	found, err := c.backend.GetShortURLs(missKeys)
+	if err != nil {
+		return nil, err
+	}
+	var hitKeys, hitVals []string
+	for j, val := range found {
+		if val == "" {
+			continue
+		}
+		vals[missIdx[j]] = val
+		hitKeys = append(hitKeys, missKeys[j])
+		hitVals = append(hitVals, val)
+	}
+	c.cache.SetShortURLs(hitKeys, hitVals)
+	return vals, nil
+}
+
+func (c *cacheStorage) SetLink(idKey string, link *Link) error {
+	if err := c.backend.SetLink(idKey, link); err != nil {
+		return err
+	}
+
+	c.cache.SetLink(idKey, link)
+	return nil
+}
+
+// AddLink caches the link added to the backend only if the code is not
+// cached. A cached one is stale, e.g. left by another backend, so it is
+// dropped rather than overwritten, and the link is read from the backend.
+func (c *cacheStorage) AddLink(idKey string, link *Link) (bool, error) {
+	ok, err := c.backend.AddLink(idKey, link)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	if cached, err := c.cache.AddLink(idKey, link); err != nil || !cached {
+		c.cache.DelLink(idKey)
+	}
+	return true, nil
+}
+
+// AddLinks caches the links added to the backend like AddLink.
+func (c *cacheStorage) AddLinks(idKeys []string, links []*Link) ([]bool, error) {
+	added, err := c.backend.AddLinks(idKeys, links)
+	if err != nil {
+		return added, err
+	}
+
+	var keys []string
+	var vals []*Link
+	for i, ok := range added {
+		if ok {
+			keys = append(keys, idKeys[i])
+			vals = append(vals, links[i])
+		}
+	}
+	cached, err := c.cache.AddLinks(keys, vals)
+	for i, idKey := range keys {
+		if err != nil || i >= len(cached) || !cached[i] {
+			c.cache.DelLink(idKey)
+		}
+	}
+	return added, nil
+}
+
+func (c *cacheStorage) SetShortURL(idKey string, val string) error {
+	if err := c.backend.SetShortURL(idKey, val); err != nil {
+		return err
+	}
+
+	c.cache.SetShortURL(idKey, val)
+	return nil
+}
+
+func (c *cacheStorage) SetShortURLs(idKeys []string, vals []string) error {
+	if err := c.backend.SetShortURLs(idKeys, vals); err != nil {
+		return err
+	}
+
+	c.cache.SetShortURLs(idKeys, vals)
+	return nil
+}
+
+func (c *cacheStorage) DelShortURL(idKey string) error {
+	if err := c.backend.DelShortURL(idKey); err != nil {
+		return err
+	}
+
+	c.cache.DelShortURL(idKey)
+	return nil
+}
+
+// The clicks are written frequently, so they are not cached.
+
+func (c *cacheStorage) IncrClicks(idKey string, n int64) error {
+	return c.backend.IncrClicks(idKey, n)
+}
+
+func (c *cacheStorage) GetClicks(idKey string) (int64, error) {
+	return c.backend.GetClicks(idKey)
+}
+
+func (c *cacheStorage) AddClickEvents(clicks []Click) error {
+	return c.backend.AddClickEvents(clicks)
+}
+
+func (c *cacheStorage) GetStats(idKey string) (*Stats, error) {
+	return c.backend.GetStats(idKey)
+}
+
+// The cache may miss links, so they are listed from the backend.
+
+func (c *cacheStorage) ListLinks(opts ListOptions) ([]*Entry, error) {
+	return c.backend.ListLinks(opts)
+}
+
+// The API keys are revoked at any time, so they are not cached.
+
+func (c *cacheStorage) GetAPIKey(id string) (*APIKey, error) {
+	return c.backend.GetAPIKey(id)
+}
+
+func (c *cacheStorage) SetAPIKey(key *APIKey) error {
+	return c.backend.SetAPIKey(key)
+}
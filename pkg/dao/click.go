@@ -0,0 +1,69 @@
+package dao
+
+import (
+	"net/url"
+	"time"
+)
+
+// Click is the event of following a short link.
+type Click struct {
+	Time      time.Time
+	ShortCode string
+	Referrer  string
+	UserAgent string
+	Browser   string
+	Device    string
+	Country   string
+	IPHash    string
+}
+
+// Stats is the aggregation of the clicks of a short link. The days are keyed
+// by "2006-01-02" and the hours are keyed by "2006-01-02T15" in UTC, the
+// referrers are keyed by their hosts, and the others are keyed by their values.
+type Stats struct {
+	Visitors  int64
+	Days      map[string]int64
+	Hours     map[string]int64
+	Referrers map[string]int64
+	Browsers  map[string]int64
+	Devices   map[string]int64
+	Countries map[string]int64
+}
+
+const (
+	// layout of the stats keys
+	statsDayLayout  = "2006-01-02"
+	statsHourLayout = "2006-01-02T15"
+)
+
+// newStats returns an empty Stats.
+func newStats() *Stats {
+	return &Stats{
+		Days:      make(map[string]int64),
+		Hours:     make(map[string]int64),
+		Referrers: make(map[string]int64),
+		Browsers:  make(map[string]int64),
+		Devices:   make(map[string]int64),
+		Countries: make(map[string]int64),
+	}
+}
+
+// add aggregates the click into the stats, except the visitors.
+func (s *Stats) add(c *Click) {
+	t := c.Time.UTC()
+	s.Days[t.Format(statsDayLayout)]++
+	s.Hours[t.Format(statsHourLayout)]++
+	s.Referrers[referrerHost(c.Referrer)]++
+	s.Browsers[c.Browser]++
+	s.Devices[c.Device]++
+	s.Countries[c.Country]++
+}
+
+// referrerHost returns the host of the referrer, empty for the direct visits.
+func referrerHost(referrer string) string {
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -2,45 +2,211 @@ package dao
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/go-redis/redis"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+)
+
+const (
+	// storage driver
+	DriverRedis  = "redis"
+	DriverMysql  = "mysql"
+	DriverMemory = "memory"
+)
+
+var (
+	// ErrUnknownDriver is returned when the storage driver is not supported.
+	ErrUnknownDriver = errors.New("unknown storage driver")
+
+	// ErrNoClient is returned when the client of the storage driver is missing.
+	ErrNoClient = errors.New("storage client is missing")
+
+	// ErrInvalidCache is returned when the cache can not be in front of the
+	// storage driver.
+	ErrInvalidCache = errors.New("invalid storage cache")
 )
 
-var db *sql.DB
-var re *redis.Client
+// Storage describes the storage of the mapping between short and long URLs.
+//
+// The link is keyed by the short code, and the short code is keyed by the
+// md5 of the long URL. A missing key gets a zero value and a nil error.
+// AddLink sets the link only if the short code is not taken yet, and reports
+// whether it is set. The clicks of the link are counted by the short code,
+// and the click events are aggregated into the stats of the short code.
+// ListLinks returns a page of the links matching the options. The API keys are
+// keyed by their IDs.
+//
+// The plural methods are the batches of the singular ones, for many links at
+// a time. Their results are in the order of the keys.
+type Storage interface {
+	GenerateID() (int64, error)
+	GenerateIDs(n int) ([]int64, error)
+	GetLink(idKey string) (*Link, error)
+	GetLinks(idKeys []string) ([]*Link, error)
+	SetLink(idKey string, link *Link) error
+	AddLink(idKey string, link *Link) (bool, error)
+	AddLinks(idKeys []string, links []*Link) ([]bool, error)
+	GetShortURL(idKey string) (string, error)
+	GetShortURLs(idKeys []string) ([]string, error)
+	SetShortURL(idKey string, val string) error
+	SetShortURLs(idKeys []string, vals []string) error
+	DelShortURL(idKey string) error
+	IncrClicks(idKey string, n int64) error
+	GetClicks(idKey string) (int64, error)
+	AddClickEvents(clicks []Click) error
+	GetStats(idKey string) (*Stats, error)
+	ListLinks(opts ListOptions) ([]*Entry, error)
+	GetAPIKey(id string) (*APIKey, error)
+	SetAPIKey(key *APIKey) error
+}
+
+// NewStorage returns the Storage selected by the [storage] config. The clients
+// of the unused drivers may be nil.
+func NewStorage(conf *config.Config, db *sql.DB, re *redis.Client) (Storage, error) {
+	var s Storage
+	driver := conf.Storage.Driver
+	switch driver {
+	case DriverRedis, "":
+		if re == nil {
+			return nil, ErrNoClient
+		}
+		s = NewRedisStorage(re)
+	case DriverMysql:
+		if db == nil {
+			return nil, ErrNoClient
+		}
+		s = NewMysqlStorage(db)
+	case DriverMemory:
+		s = NewMemoryStorage()
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+	}
+
+	switch cache := conf.Storage.Cache; cache {
+	case "":
+	case DriverRedis:
+		if driver == DriverRedis || driver == "" {
+			// the cache would share the keys of the storage, and invalidate
+			// the links themselves
+			return nil, fmt.Errorf("%w: %s in front of the %s driver", ErrInvalidCache, cache, DriverRedis)
+		}
+		if re == nil {
+			return nil, ErrNoClient
+		}
+		s = NewCacheStorage(&redisStorage{client: re}, s)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, cache)
+	}
+
+	return s, nil
+}
 
 // Dao struct
 type Dao struct {
+	storage Storage
 }
 
 // New Dao
-func New(d *sql.DB, r *redis.Client) *Dao {
-	db = d
-	re = r
-	return &Dao{}
+func New(s Storage) *Dao {
+	return &Dao{
+		storage: s,
+	}
 }
 
 // GenerateID ...
 func (dao *Dao) GenerateID() (int64, error) {
-	return _r.generateID()
+	return dao.storage.GenerateID()
+}
+
+// GenerateIDs ...
+func (dao *Dao) GenerateIDs(n int) ([]int64, error) {
+	return dao.storage.GenerateIDs(n)
+}
+
+// GetLink ...
+func (dao *Dao) GetLink(idKey string) (*Link, error) {
+	return dao.storage.GetLink(idKey)
 }
 
-// GetLongURL ...
-func (dao *Dao) GetLongURL(idKey string) (string, error) {
-	return _r.getLongURL(idKey)
+// GetLinks ...
+func (dao *Dao) GetLinks(idKeys []string) ([]*Link, error) {
+	return dao.storage.GetLinks(idKeys)
 }
 
 // GetShortURL ...
 func (dao *Dao) GetShortURL(idKey string) (string, error) {
-	return _r.getShortURL(idKey)
+	return dao.storage.GetShortURL(idKey)
+}
+
+// GetShortURLs ...
+func (dao *Dao) GetShortURLs(idKeys []string) ([]string, error) {
+	return dao.storage.GetShortURLs(idKeys)
+}
+
+// SetLink ...
+func (dao *Dao) SetLink(idKey string, link *Link) error {
+	return dao.storage.SetLink(idKey, link)
 }
 
-// SetLongURL ...
-func (dao *Dao) SetLongURL(idKey string, val string) error {
-	return _r.setLongURL(idKey, val)
+// AddLink ...
+func (dao *Dao) AddLink(idKey string, link *Link) (bool, error) {
+	return dao.storage.AddLink(idKey, link)
+}
+
+// AddLinks ...
+func (dao *Dao) AddLinks(idKeys []string, links []*Link) ([]bool, error) {
+	return dao.storage.AddLinks(idKeys, links)
 }
 
 // SetShortURL ...
 func (dao *Dao) SetShortURL(idKey string, val string) error {
-	return _r.setShortURL(idKey, val)
+	return dao.storage.SetShortURL(idKey, val)
+}
+
+// SetShortURLs ...
+func (dao *Dao) SetShortURLs(idKeys []string, vals []string) error {
+	return dao.storage.SetShortURLs(idKeys, vals)
+}
+
+// DelShortURL ...
+func (dao *Dao) DelShortURL(idKey string) error {
+	return dao.storage.DelShortURL(idKey)
+}
+
+// IncrClicks ...
+func (dao *Dao) IncrClicks(idKey string, n int64) error {
+	return dao.storage.IncrClicks(idKey, n)
+}
+
+// GetClicks ...
+func (dao *Dao) GetClicks(idKey string) (int64, error) {
+	return dao.storage.GetClicks(idKey)
+}
+
+// AddClickEvents ...
+func (dao *Dao) AddClickEvents(clicks []Click) error {
+	return dao.storage.AddClickEvents(clicks)
+}
+
+// GetStats ...
+func (dao *Dao) GetStats(idKey string) (*Stats, error) {
+	return dao.storage.GetStats(idKey)
+}
+
+// ListLinks ...
+func (dao *Dao) ListLinks(opts ListOptions) ([]*Entry, error) {
+	return dao.storage.ListLinks(opts)
+}
+
+// GetAPIKey ...
+func (dao *Dao) GetAPIKey(id string) (*APIKey, error) {
+	return dao.storage.GetAPIKey(id)
+}
+
+// SetAPIKey ...
+func (dao *Dao) SetAPIKey(key *APIKey) error {
+	return dao.storage.SetAPIKey(key)
 }
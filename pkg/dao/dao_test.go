@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+)
+
+func TestNewStorage(t *testing.T) {
+	// the client connects lazily, nothing is sent to it here
+	re := redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	defer re.Close()
+
+	cases := []struct {
+		driver string
+		cache  string
+		re     *redis.Client
+		err    error
+	}{
+		{DriverMemory, "", nil, nil},
+		{DriverMemory, DriverRedis, re, nil},
+		{DriverMemory, DriverRedis, nil, ErrNoClient},
+		{DriverRedis, "", re, nil},
+		{DriverRedis, "", nil, ErrNoClient},
+		{DriverRedis, DriverRedis, re, ErrInvalidCache},
+		{"", DriverRedis, re, ErrInvalidCache},
+		{DriverMysql, "", nil, ErrNoClient},
+		{"bolt", "", nil, ErrUnknownDriver},
+		{DriverMemory, "memcached", nil, ErrUnknownDriver},
+	}
+	for _, c := range cases {
+		conf := &config.Config{}
+		conf.Storage.Driver = c.driver
+		conf.Storage.Cache = c.cache
+		s, err := NewStorage(conf, nil, c.re)
+		if c.err == nil && (err != nil || s == nil) {
+			t.Errorf("driver %q, cache %q: got %v, %v", c.driver, c.cache, s, err)
+		}
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("driver %q, cache %q: got %v, want %v", c.driver, c.cache, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package dao
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis"
+)
+
+// ErrIDBehind is returned when the id generator of mysql is behind the one of
+// redis, so mysql would issue the codes which are live in redis.
+var ErrIDBehind = errors.New("mysql id generator is behind redis, import redis first")
+
+// Imported is the numbers of the records imported by ImportRedis.
+type Imported struct {
+	Links     int
+	ShortURLs int
+	Clicks    int
+	APIKeys   int
+}
+
+// ImportRedis copies the links, the indexes of the long URLs, the clicks and
+// the API keys of the redis storage into mysql, and moves the id generator of
+// mysql past the one of redis. The records already in mysql are kept, so it
+// may be run again. The stats of the clicks are not imported, they are
+// aggregated in redis without the events.
+//
+// The redis storage is expected to be stopped, the links created during the
+// import may be missed.
+func ImportRedis(client *redis.Client, db *sql.DB) (*Imported, error) {
+	lastID, err := redisID(client)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mysqlStorage{db: db}
+	r := &redisStorage{client: client}
+	imported := &Imported{}
+
+	err = scanKeys(client, fmt.Sprintf(cacheLongKey, ""), func(idKeys []string) error {
+		links, err := r.GetLinks(idKeys)
+		if err != nil {
+			return err
+		}
+		// expired between SCAN and GET
+		var keys []string
+		var vals []*Link
+		for i, link := range links {
+			if link != nil {
+				keys = append(keys, idKeys[i])
+				vals = append(vals, link)
+			}
+		}
+		added, err := m.AddLinks(keys, vals)
+		for _, ok := range added {
+			if ok {
+				imported.Links++
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanKeys(client, fmt.Sprintf(cacheShortKey, ""), func(shortKeys []string) error {
+		vals, err := r.GetShortURLs(shortKeys)
+		if err != nil {
+			return err
+		}
+		n, err := m.importShortURLs(shortKeys, vals)
+		imported.ShortURLs += n
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanKeys(client, fmt.Sprintf(cacheClickKey, ""), func(idKeys []string) error {
+		for _, idKey := range idKeys {
+			clicks, err := r.GetClicks(idKey)
+			if err != nil {
+				return err
+			}
+			if err := m.importClicks(idKey, clicks); err != nil {
+				return err
+			}
+			imported.Clicks++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = scanKeys(client, fmt.Sprintf(cacheKeyKey, ""), func(ids []string) error {
+		for _, id := range ids {
+			key, err := r.GetAPIKey(id)
+			if err != nil || key == nil {
+				return err
+			}
+			old, err := m.GetAPIKey(id)
+			if err != nil || old != nil {
+				return err
+			}
+			if err := m.SetAPIKey(key); err != nil {
+				return err
+			}
+			imported.APIKeys++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(`UPDATE `+tableID+` SET id = ? WHERE stub = 'a' AND id < ?`, lastID, lastID)
+	if err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// CheckRedisID returns ErrIDBehind if the id generator of mysql is behind the
+// one of redis, e.g. redis is not imported before switching to mysql.
+func CheckRedisID(client *redis.Client, db *sql.DB) error {
+	lastID, err := redisID(client)
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	err = db.QueryRow(`SELECT id FROM ` + tableID + ` WHERE stub = 'a'`).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errNoCounter
+	}
+	if err != nil {
+		return err
+	}
+	if id < lastID {
+		return fmt.Errorf("%w: %d < %d", ErrIDBehind, id, lastID)
+	}
+	return nil
+}
+
+// redisID returns the last ID generated by redis, 0 if none.
+func redisID(client *redis.Client) (int64, error) {
+	id, err := client.Get(cacheIDKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return id, err
+}
+
+// scanKeys calls fn with the keys of the prefix by the batches of SCAN, the
+// prefix is trimmed from the keys.
+func scanKeys(client *redis.Client, prefix string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, prefix+"*", listScanCount).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			for i, key := range keys {
+				keys[i] = strings.TrimPrefix(key, prefix)
+			}
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// importShortURLs inserts the indexes of the long URLs which are not in mysql
+// yet, and returns the number of them.
+func (m *mysqlStorage) importShortURLs(shortKeys []string, vals []string) (int, error) {
+	var values []string
+	var args []interface{}
+	for i, shortKey := range shortKeys {
+		// expired between SCAN and GET
+		if vals[i] == "" {
+			continue
+		}
+		values = append(values, "(?, ?, UNIX_TIMESTAMP(), UNIX_TIMESTAMP())")
+		args = append(args, shortKey, vals[i])
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	res, err := m.db.Exec(`INSERT IGNORE INTO `+tableShort+` (short_key, id_key, created_at, updated_at) VALUES `+strings.Join(values, ", "), args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// importClicks keeps the larger of the clicks, so the clicks are not counted
+// twice by the imports.
+func (m *mysqlStorage) importClicks(idKey string, clicks int64) error {
+	_, err := m.db.Exec(`INSERT INTO `+tableClick+` (id_key, clicks, updated_at) VALUES (?, ?, UNIX_TIMESTAMP())
+		ON DUPLICATE KEY UPDATE clicks = GREATEST(clicks, VALUES(clicks)), updated_at = VALUES(updated_at)`, idKey, clicks)
+	return err
+}
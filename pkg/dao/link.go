@@ -0,0 +1,85 @@
+package dao
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Link is the record of a short code.
+type Link struct {
+	LongURL string `json:"long_url"`
+
+	// ExpiresAt is the unix time the link expires at, 0 means never.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+
+	// CreatedAt is the unix time the link is created at, 0 for the early
+	// records.
+	CreatedAt int64  `json:"created_at,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+
+	Title    string   `json:"title,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Notes    string   `json:"notes,omitempty"`
+	Campaign string   `json:"campaign,omitempty"`
+
+	// UTM is the utm parameters of the long URL, nil if it has none.
+	UTM *UTM `json:"utm,omitempty"`
+
+	// Redirect is the type of the redirect, the status code or interstitial,
+	// the one of the config if empty.
+	Redirect string `json:"redirect,omitempty"`
+
+	// Disabled links are kept but not redirected, they may be enabled again.
+	Disabled bool `json:"disabled,omitempty"`
+
+	// DeletedAt is the unix time the link is deleted at, 0 means not deleted.
+	// Deleted links are kept, so their short codes are never reused.
+	DeletedAt int64 `json:"deleted_at,omitempty"`
+}
+
+// UTM is the utm parameters of a long URL.
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// Expired reports whether the link is expired at the time.
+func (l *Link) Expired(now time.Time) bool {
+	return l.ExpiresAt > 0 && l.ExpiresAt <= now.Unix()
+}
+
+// Live reports whether the link is redirected at the time.
+func (l *Link) Live(now time.Time) bool {
+	return !l.Disabled && l.DeletedAt == 0 && !l.Expired(now)
+}
+
+// clone returns a copy of the link which shares nothing with it.
+func (l *Link) clone() *Link {
+	c := *l
+	if l.Tags != nil {
+		c.Tags = append([]string(nil), l.Tags...)
+	}
+	if l.UTM != nil {
+		utm := *l.UTM
+		c.UTM = &utm
+	}
+	return &c
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, it is used by redis.
+func (l *Link) MarshalBinary() ([]byte, error) {
+	return json.Marshal(l)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it is used by redis.
+// The early records are the raw long URL rather than JSON.
+func (l *Link) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != '{' {
+		*l = Link{LongURL: string(data)}
+		return nil
+	}
+	return json.Unmarshal(data, l)
+}
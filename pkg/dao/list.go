@@ -0,0 +1,142 @@
+package dao
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// sort of listing links
+	SortCreatedAt = "created_at"
+	SortClicks    = "clicks"
+)
+
+// ListOptions collects the filters, sort and page of listing links. The
+// zero values of the filters match all. The deleted links are never listed.
+type ListOptions struct {
+	Tag       string
+	CreatedBy string
+
+	// CreatedAfter and CreatedBefore are unix time, the range is [after, before).
+	CreatedAfter  int64
+	CreatedBefore int64
+
+	// LongURL is a substring of the long URL, and Domain matches the host of
+	// the long URL and its subdomains.
+	LongURL string
+	Domain  string
+
+	// ShortHost limits the links to the short domain of the host, the codes
+	// of which are keyed by "host/code", "" is the default domain. Nil
+	// matches all of the short domains.
+	ShortHost *string
+
+	// Sort is SortCreatedAt or SortClicks, ties are broken by the short code.
+	Sort string
+	Desc bool
+
+	// After is the last entry of the previous page, nil for the first page.
+	After *Cursor
+	Limit int
+}
+
+// Cursor is the position of an entry in the sort.
+type Cursor struct {
+	Value int64  `json:"v"`
+	IDKey string `json:"k"`
+}
+
+// Entry is a listed link.
+type Entry struct {
+	IDKey  string
+	Link   *Link
+	Clicks int64
+}
+
+// Cursor returns the position of the entry in the sort.
+func (e *Entry) Cursor(sortBy string) *Cursor {
+	if sortBy == SortClicks {
+		return &Cursor{Value: e.Clicks, IDKey: e.IDKey}
+	}
+	return &Cursor{Value: e.Link.CreatedAt, IDKey: e.IDKey}
+}
+
+// match reports whether the link of the key matches the filters.
+func (o *ListOptions) match(idKey string, link *Link) bool {
+	if link.DeletedAt > 0 {
+		return false
+	}
+	if o.ShortHost != nil && !matchShortHost(idKey, *o.ShortHost) {
+		return false
+	}
+	if o.CreatedBy != "" && link.CreatedBy != o.CreatedBy {
+		return false
+	}
+	if o.CreatedAfter > 0 && link.CreatedAt < o.CreatedAfter {
+		return false
+	}
+	if o.CreatedBefore > 0 && link.CreatedAt >= o.CreatedBefore {
+		return false
+	}
+	if o.LongURL != "" && !strings.Contains(link.LongURL, o.LongURL) {
+		return false
+	}
+	if o.Domain != "" && !matchDomain(link.LongURL, o.Domain) {
+		return false
+	}
+	if o.Tag != "" {
+		for _, tag := range link.Tags {
+			if tag == o.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchShortHost(idKey string, host string) bool {
+	if host == "" {
+		return !strings.Contains(idKey, "/")
+	}
+	return strings.HasPrefix(idKey, host+"/")
+}
+
+func matchDomain(longURL string, domain string) bool {
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// page sorts the entries and returns the page of the options, it is used by
+// the storages which can't sort by themselves.
+func (o *ListOptions) page(entries []*Entry) []*Entry {
+	less := func(a *Cursor, b *Cursor) bool {
+		switch {
+		case a.Value != b.Value:
+			return (a.Value < b.Value) != o.Desc
+		case a.IDKey != b.IDKey:
+			return (a.IDKey < b.IDKey) != o.Desc
+		}
+		return false
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i].Cursor(o.Sort), entries[j].Cursor(o.Sort))
+	})
+
+	if o.After != nil {
+		i := sort.Search(len(entries), func(i int) bool {
+			return less(o.After, entries[i].Cursor(o.Sort))
+		})
+		entries = entries[i:]
+	}
+	if o.Limit > 0 && len(entries) > o.Limit {
+		entries = entries[:o.Limit]
+	}
+	return entries
+}
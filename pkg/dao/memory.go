@@ -0,0 +1,222 @@
+package dao
+
+import (
+	"sync"
+)
+
+// NewMemoryStorage returns a Storage kept in the process memory. It is safe
+// for concurrent use, and is meant for tests and local development.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{
+		links:  make(map[string]*Link),
+		shorts: make(map[string]string),
+		clicks: make(map[string]int64),
+		stats:  make(map[string]*Stats),
+		uvs:    make(map[string]map[string]struct{}),
+		keys:   make(map[string]*APIKey),
+	}
+}
+
+type memoryStorage struct {
+	mtx    sync.RWMutex
+	id     int64
+	links  map[string]*Link
+	shorts map[string]string
+	clicks map[string]int64
+	stats  map[string]*Stats
+	uvs    map[string]map[string]struct{}
+	keys   map[string]*APIKey
+}
+
+func (m *memoryStorage) GenerateID() (int64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// first access
+	if m.id == 0 {
+		m.id = defaultID
+	} else {
+		m.id++
+	}
+
+	return m.id, nil
+}
+
+func (m *memoryStorage) GenerateIDs(n int) ([]int64, error) {
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i], _ = m.GenerateID()
+	}
+	return ids, nil
+}
+
+func (m *memoryStorage) GetLink(idKey string) (*Link, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	link, ok := m.links[idKey]
+	if !ok {
+		return nil, nil
+	}
+	return link.clone(), nil
+}
+
+func (m *memoryStorage) GetShortURL(idKey string) (string, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.shorts[idKey], nil
+}
+
+func (m *memoryStorage) GetLinks(idKeys []string) ([]*Link, error) {
+	links := make([]*Link, len(idKeys))
+	for i, idKey := range idKeys {
+		links[i], _ = m.GetLink(idKey)
+	}
+	return links, nil
+}
+
+func (m *memoryStorage) GetShortURLs(idKeys []string) ([]string, error) {
+	vals := make([]string, len(idKeys))
+	for i, idKey := range idKeys {
+		vals[i], _ = m.GetShortURL(idKey)
+	}
+	return vals, nil
+}
+
+func (m *memoryStorage) SetLink(idKey string, link *Link) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.links[idKey] = link.clone()
+	return nil
+}
+
+func (m *memoryStorage) AddLink(idKey string, link *Link) (bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if _, ok := m.links[idKey]; ok {
+		return false, nil
+	}
+	m.links[idKey] = link.clone()
+	return true, nil
+}
+
+func (m *memoryStorage) SetShortURL(idKey string, val string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.shorts[idKey] = val
+	return nil
+}
+
+func (m *memoryStorage) AddLinks(idKeys []string, links []*Link) ([]bool, error) {
+	added := make([]bool, len(idKeys))
+	for i, idKey := range idKeys {
+		added[i], _ = m.AddLink(idKey, links[i])
+	}
+	return added, nil
+}
+
+func (m *memoryStorage) SetShortURLs(idKeys []string, vals []string) error {
+	for i, idKey := range idKeys {
+		m.SetShortURL(idKey, vals[i])
+	}
+	return nil
+}
+
+func (m *memoryStorage) DelLink(idKey string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.links, idKey)
+	return nil
+}
+
+func (m *memoryStorage) DelShortURL(idKey string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.shorts, idKey)
+	return nil
+}
+
+func (m *memoryStorage) IncrClicks(idKey string, n int64) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.clicks[idKey] += n
+	return nil
+}
+
+func (m *memoryStorage) GetClicks(idKey string) (int64, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.clicks[idKey], nil
+}
+
+func (m *memoryStorage) AddClickEvents(clicks []Click) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for i := range clicks {
+		c := &clicks[i]
+		stats, ok := m.stats[c.ShortCode]
+		if !ok {
+			stats = newStats()
+			m.stats[c.ShortCode] = stats
+			m.uvs[c.ShortCode] = make(map[string]struct{})
+		}
+		stats.add(c)
+		if c.IPHash != "" {
+			m.uvs[c.ShortCode][c.IPHash] = struct{}{}
+		}
+		stats.Visitors = int64(len(m.uvs[c.ShortCode]))
+	}
+	return nil
+}
+
+func (m *memoryStorage) GetStats(idKey string) (*Stats, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	stats := newStats()
+	if s, ok := m.stats[idKey]; ok {
+		stats.Visitors = s.Visitors
+		for _, pair := range [][2]map[string]int64{
+			{stats.Days, s.Days},
+			{stats.Hours, s.Hours},
+			{stats.Referrers, s.Referrers},
+			{stats.Browsers, s.Browsers},
+			{stats.Devices, s.Devices},
+			{stats.Countries, s.Countries},
+		} {
+			for k, v := range pair[1] {
+				pair[0][k] = v
+			}
+		}
+	}
+	return stats, nil
+}
+
+func (m *memoryStorage) ListLinks(opts ListOptions) ([]*Entry, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	var entries []*Entry
+	for idKey, link := range m.links {
+		if !opts.match(idKey, link) {
+			continue
+		}
+		entries = append(entries, &Entry{IDKey: idKey, Link: link.clone(), Clicks: m.clicks[idKey]})
+	}
+	return opts.page(entries), nil
+}
+
+func (m *memoryStorage) GetAPIKey(id string) (*APIKey, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	key, ok := m.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	return key.clone(), nil
+}
+
+func (m *memoryStorage) SetAPIKey(key *APIKey) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.keys[key.ID] = key.clone()
+	return nil
+}
@@ -0,0 +1,41 @@
+package dao
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateIDs(t *testing.T) {
+	m := NewMemoryStorage()
+	first, err := m.GenerateID()
+	if err != nil || first != defaultID {
+		t.Fatalf("got %d, %v, want %d", first, err, defaultID)
+	}
+	ids, err := m.GenerateIDs(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{defaultID + 1, defaultID + 2, defaultID + 3}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got %v, want %v", ids, want)
+	}
+	if next, _ := m.GenerateID(); next != defaultID+4 {
+		t.Fatalf("got %d after the range, want %d", next, defaultID+4)
+	}
+}
+
+func TestIDRange(t *testing.T) {
+	cases := []struct {
+		last int64
+		n    int
+		want []int64
+	}{
+		{defaultID, 1, []int64{defaultID}},
+		{defaultID + 2, 3, []int64{defaultID, defaultID + 1, defaultID + 2}},
+		{12, 0, []int64{}},
+	}
+	for _, c := range cases {
+		if got := idRange(c.last, c.n); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("idRange(%d, %d): got %v, want %v", c.last, c.n, got, c.want)
+		}
+	}
+}
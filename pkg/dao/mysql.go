@@ -1,13 +1,806 @@
 package dao
 
-const ()
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
-var _d d
+const (
+	// table name
+	tablePre       = "surl_"
+	tableMigration = tablePre + "migration"
+	tableID        = tablePre + "id"
+	tableShort     = tablePre + "short"
+	tableLong      = tablePre + "long"
+	tableClick     = tablePre + "click"
+	tableEvent     = tablePre + "click_event"
+	tableKey       = tablePre + "api_key"
+)
 
-type d struct {
+// migrations is the schema of mysql storage. Every element is one version,
+// and they are applied in order. Never change an applied element, append a
+// new one instead.
+var migrations = []string{
+	// 1. id generator, works as a ticket server and starts at defaultID
+	`CREATE TABLE IF NOT EXISTS ` + tableID + ` (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		stub CHAR(1) NOT NULL DEFAULT '',
+		PRIMARY KEY (id),
+		UNIQUE KEY uk_stub (stub)
+	) ENGINE=InnoDB AUTO_INCREMENT=10000 DEFAULT CHARSET=utf8mb4`,
+
+	// 2. short code -> long URL
+	`CREATE TABLE IF NOT EXISTS ` + tableLong + ` (
+		id_key VARCHAR(64) NOT NULL,
+		long_url TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (id_key)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+
+	// 3. md5 of long URL -> short code
+	`CREATE TABLE IF NOT EXISTS ` + tableShort + ` (
+		short_key CHAR(32) NOT NULL,
+		id_key VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (short_key)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+
+	// 4. link expiration, unix time and 0 means never
+	`ALTER TABLE ` + tableLong + ` ADD COLUMN expires_at BIGINT NOT NULL DEFAULT 0 AFTER long_url`,
+
+	// 5. click counter of short code
+	`CREATE TABLE IF NOT EXISTS ` + tableClick + ` (
+		id_key VARCHAR(64) NOT NULL,
+		clicks BIGINT UNSIGNED NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		PRIMARY KEY (id_key)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+
+	// 6. click events, clicked_at is in UTC
+	`CREATE TABLE IF NOT EXISTS ` + tableEvent + ` (
+		id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+		id_key VARCHAR(64) NOT NULL,
+		clicked_at DATETIME NOT NULL,
+		referrer VARCHAR(255) NOT NULL DEFAULT '',
+		user_agent VARCHAR(512) NOT NULL DEFAULT '',
+		browser VARCHAR(32) NOT NULL DEFAULT '',
+		device VARCHAR(32) NOT NULL DEFAULT '',
+		country CHAR(2) NOT NULL DEFAULT '',
+		ip_hash CHAR(64) NOT NULL DEFAULT '',
+		PRIMARY KEY (id),
+		KEY idx_id_key_clicked_at (id_key, clicked_at)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+
+	// 7. link metadata, tags is a JSON array
+	`ALTER TABLE ` + tableLong + `
+		ADD COLUMN created_by VARCHAR(128) NOT NULL DEFAULT '' AFTER expires_at,
+		ADD COLUMN title VARCHAR(255) NOT NULL DEFAULT '' AFTER created_by,
+		ADD COLUMN tags VARCHAR(1024) NOT NULL DEFAULT '' AFTER title,
+		ADD COLUMN notes VARCHAR(4096) NOT NULL DEFAULT '' AFTER tags,
+		ADD COLUMN campaign VARCHAR(128) NOT NULL DEFAULT '' AFTER notes`,
+
+	// 8. disabled and deleted links, deleted_at is unix time and 0 means not
+	`ALTER TABLE ` + tableLong + `
+		ADD COLUMN disabled TINYINT(1) NOT NULL DEFAULT 0 AFTER campaign,
+		ADD COLUMN deleted_at BIGINT NOT NULL DEFAULT 0 AFTER disabled`,
+
+	// 9. API keys, hash is the sha256 of the secret and scopes is a JSON array
+	`CREATE TABLE IF NOT EXISTS ` + tableKey + ` (
+		id VARCHAR(32) NOT NULL,
+		hash CHAR(64) NOT NULL,
+		name VARCHAR(128) NOT NULL DEFAULT '',
+		scopes VARCHAR(255) NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL DEFAULT 0,
+		created_by VARCHAR(128) NOT NULL DEFAULT '',
+		revoked_at BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (id)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+
+	// 10. short domain of API keys
+	`ALTER TABLE ` + tableKey + ` ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER scopes`,
+
+	// 11-14. short codes namespaced by the hosts of short domains
+	`ALTER TABLE ` + tableLong + ` MODIFY id_key VARCHAR(320) NOT NULL`,
+	`ALTER TABLE ` + tableShort + ` MODIFY id_key VARCHAR(320) NOT NULL`,
+	`ALTER TABLE ` + tableClick + ` MODIFY id_key VARCHAR(320) NOT NULL`,
+	`ALTER TABLE ` + tableEvent + ` MODIFY id_key VARCHAR(320) NOT NULL`,
+
+	// 15. type of the redirect of links, empty is the one of the config
+	`ALTER TABLE ` + tableLong + ` ADD COLUMN redirect VARCHAR(16) NOT NULL DEFAULT '' AFTER campaign`,
+
+	// 16. utm parameters of the long URLs
+	`ALTER TABLE ` + tableLong + `
+		ADD COLUMN utm_source VARCHAR(128) NOT NULL DEFAULT '' AFTER campaign,
+		ADD COLUMN utm_medium VARCHAR(128) NOT NULL DEFAULT '' AFTER utm_source,
+		ADD COLUMN utm_campaign VARCHAR(128) NOT NULL DEFAULT '' AFTER utm_medium,
+		ADD COLUMN utm_term VARCHAR(128) NOT NULL DEFAULT '' AFTER utm_campaign,
+		ADD COLUMN utm_content VARCHAR(128) NOT NULL DEFAULT '' AFTER utm_term`,
+
+	// 17. the id generator is a counter row, the last ticket taken, so a range
+	// is reserved by a single update. The row of the tickets taken by REPLACE
+	// is kept as it is.
+	`INSERT IGNORE INTO ` + tableID + ` (id, stub) VALUES (` + strconv.Itoa(defaultID-1) + `, 'a')`,
+
+	// 18-21. the times of links are unix time, TIMESTAMP overflows in 2038 and
+	// is read in the time zone of the session. The new columns are filled,
+	// then take the place of the old ones.
+	`ALTER TABLE ` + tableLong + `
+		ADD COLUMN created_unix BIGINT NOT NULL DEFAULT 0 AFTER updated_at,
+		ADD COLUMN updated_unix BIGINT NOT NULL DEFAULT 0 AFTER created_unix`,
+	`UPDATE ` + tableLong + ` SET created_unix = UNIX_TIMESTAMP(created_at), updated_unix = UNIX_TIMESTAMP(updated_at)`,
+	`ALTER TABLE ` + tableLong + ` DROP COLUMN created_at, DROP COLUMN updated_at`,
+	`ALTER TABLE ` + tableLong + `
+		CHANGE created_unix created_at BIGINT NOT NULL DEFAULT 0,
+		CHANGE updated_unix updated_at BIGINT NOT NULL DEFAULT 0`,
+
+	// 22-25. the times of short keys are unix time, like the links
+	`ALTER TABLE ` + tableShort + `
+		ADD COLUMN created_unix BIGINT NOT NULL DEFAULT 0 AFTER updated_at,
+		ADD COLUMN updated_unix BIGINT NOT NULL DEFAULT 0 AFTER created_unix`,
+	`UPDATE ` + tableShort + ` SET created_unix = UNIX_TIMESTAMP(created_at), updated_unix = UNIX_TIMESTAMP(updated_at)`,
+	`ALTER TABLE ` + tableShort + ` DROP COLUMN created_at, DROP COLUMN updated_at`,
+	`ALTER TABLE ` + tableShort + `
+		CHANGE created_unix created_at BIGINT NOT NULL DEFAULT 0,
+		CHANGE updated_unix updated_at BIGINT NOT NULL DEFAULT 0`,
+
+	// 26-29. the time of click counters is unix time, like the links
+	`ALTER TABLE ` + tableClick + ` ADD COLUMN updated_unix BIGINT NOT NULL DEFAULT 0 AFTER updated_at`,
+	`UPDATE ` + tableClick + ` SET updated_unix = UNIX_TIMESTAMP(updated_at)`,
+	`ALTER TABLE ` + tableClick + ` DROP COLUMN updated_at`,
+	`ALTER TABLE ` + tableClick + ` CHANGE updated_unix updated_at BIGINT NOT NULL DEFAULT 0`,
+}
+
+const (
+	// migrateLock is the name of the lock of the migrations, so the instances
+	// started at once do not apply them twice.
+	migrateLock = "short_url_migrate"
+
+	// migrateLockTimeout is the seconds to wait for the lock.
+	migrateLockTimeout = 300
+)
+
+// errNoCounter is returned when the counter row of the id generator is
+// missing, the migrations are not applied.
+var errNoCounter = errors.New("mysql: counter row of " + tableID + " is missing")
+
+// MigrateMysql applies the pending migrations to the database. They are
+// applied under a named lock of mysql, held by a connection of its own.
+func MigrateMysql(d *sql.DB) error {
+	ctx := context.Background()
+	conn, err := d.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	row := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrateLock, migrateLockTimeout)
+	if err := row.Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return fmt.Errorf("mysql: lock %s of the migrations is not taken in %ds", migrateLock, migrateLockTimeout)
+	}
+	defer conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, migrateLock)
+
+	return migrate(ctx, conn)
+}
+
+func migrate(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+tableMigration+` (
+		version INT NOT NULL,
+		applied_at BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (version)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	if err != nil {
+		return err
+	}
+
+	// the table of the versions is not versioned itself, the one created with
+	// applied_at of TIMESTAMP is converted in place
+	var appliedAt string
+	row := conn.QueryRowContext(ctx, `SELECT DATA_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = 'applied_at'`, tableMigration)
+	if err := row.Scan(&appliedAt); err != nil {
+		return err
+	}
+	if strings.EqualFold(appliedAt, "timestamp") {
+		for _, query := range []string{
+			`ALTER TABLE ` + tableMigration + ` ADD COLUMN applied_unix BIGINT NOT NULL DEFAULT 0`,
+			`UPDATE ` + tableMigration + ` SET applied_unix = UNIX_TIMESTAMP(applied_at)`,
+			`ALTER TABLE ` + tableMigration + ` DROP COLUMN applied_at`,
+			`ALTER TABLE ` + tableMigration + ` CHANGE applied_unix applied_at BIGINT NOT NULL DEFAULT 0`,
+		} {
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				return err
+			}
+		}
+	}
+
+	var version int
+	row = conn.QueryRowContext(ctx, `SELECT IFNULL(MAX(version), 0) FROM `+tableMigration)
+	if err := row.Scan(&version); err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if _, err := conn.ExecContext(ctx, migrations[i]); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, `INSERT INTO `+tableMigration+` (version, applied_at) VALUES (?, UNIX_TIMESTAMP())`, i+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewMysqlStorage returns a Storage backed by mysql, the schema should be
+// migrated by MigrateMysql first.
+func NewMysqlStorage(db *sql.DB) Storage {
+	return &mysqlStorage{db: db}
+}
+
+type mysqlStorage struct {
+	db *sql.DB
+}
+
+func (m *mysqlStorage) GenerateID() (int64, error) {
+	ids, err := m.GenerateIDs(1)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// GenerateIDs reserves n tickets by a single UPDATE of the counter row,
+// LAST_INSERT_ID returns the last of them to this connection.
+func (m *mysqlStorage) GenerateIDs(n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	res, err := m.db.Exec(`UPDATE `+tableID+` SET id = LAST_INSERT_ID(id + ?) WHERE stub = 'a'`, n)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		if err == nil {
+			err = errNoCounter
+		}
+		return nil, err
+	}
+	last, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return idRange(last, n), nil
+}
+
+// idRange returns the n IDs of a range ending at last.
+func idRange(last int64, n int) []int64 {
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = last - int64(n-1-i)
+	}
+	return ids
+}
+
+// linkColumns are the columns of a link in the order of scanLink.
+const linkColumns = `long_url, expires_at, created_at, created_by, title, tags, notes, campaign,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, redirect, disabled, deleted_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanLink scans the linkColumns, and the extra columns after them.
+func scanLink(row scanner, extra ...interface{}) (*Link, error) {
+	var tags string
+	var utm UTM
+	link := &Link{}
+	dest := []interface{}{&link.LongURL, &link.ExpiresAt, &link.CreatedAt, &link.CreatedBy,
+		&link.Title, &tags, &link.Notes, &link.Campaign, &utm.Source, &utm.Medium, &utm.Campaign,
+		&utm.Term, &utm.Content, &link.Redirect, &link.Disabled, &link.DeletedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if utm != (UTM{}) {
+		link.UTM = &utm
+	}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &link.Tags); err != nil {
+			return nil, err
+		}
+	}
+	return link, nil
+}
+
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(tags)
+	return string(b)
+}
+
+func (m *mysqlStorage) GetLink(idKey string) (*Link, error) {
+	row := m.db.QueryRow(`SELECT `+linkColumns+` FROM `+tableLong+` WHERE id_key = ?`, idKey)
+	link, err := scanLink(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (m *mysqlStorage) GetLinks(idKeys []string) ([]*Link, error) {
+	if len(idKeys) == 0 {
+		return nil, nil
+	}
+
+	rows, err := m.db.Query(`SELECT `+linkColumns+`, id_key FROM `+tableLong+` WHERE id_key IN (`+
+		placeholders(len(idKeys))+`)`, stringArgs(idKeys)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]*Link{}
+	for rows.Next() {
+		var idKey string
+		link, err := scanLink(rows, &idKey)
+		if err != nil {
+			return nil, err
+		}
+		found[idKey] = link
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	links := make([]*Link, len(idKeys))
+	for i, idKey := range idKeys {
+		links[i] = found[idKey]
+	}
+	return links, nil
+}
+
+func (m *mysqlStorage) GetShortURL(idKey string) (string, error) {
+	var v string
+	row := m.db.QueryRow(`SELECT id_key FROM `+tableShort+` WHERE short_key = ?`, idKey)
+	err := row.Scan(&v)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return v, err
+}
+
+// insertLink is the insert of a link, created_at is kept on duplicate key.
+// linkValues are the values of a row, of the linkArgs.
+const (
+	linkValues = `(?, ?, ?, IF(? > 0, ?, UNIX_TIMESTAMP()), UNIX_TIMESTAMP(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	insertLink = ` INTO ` + tableLong + ` (id_key, long_url, expires_at, created_at, updated_at, created_by, title, tags, notes, campaign,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, redirect, disabled, deleted_at)
+	VALUES `
+
+	// maxLinkRows is the max rows of an insert, for the limit of placeholders.
+	maxLinkRows = 1000
+)
+
+func linkArgs(idKey string, link *Link) []interface{} {
+	var utm UTM
+	if link.UTM != nil {
+		utm = *link.UTM
+	}
+	return []interface{}{idKey, link.LongURL, link.ExpiresAt, link.CreatedAt, link.CreatedAt, link.CreatedBy,
+		link.Title, encodeTags(link.Tags), link.Notes, link.Campaign, utm.Source, utm.Medium, utm.Campaign,
+		utm.Term, utm.Content, link.Redirect, link.Disabled, link.DeletedAt}
+}
+
+func (m *mysqlStorage) SetLink(idKey string, link *Link) error {
+	_, err := m.db.Exec(`INSERT`+insertLink+linkValues+`
+		ON DUPLICATE KEY UPDATE long_url = VALUES(long_url), expires_at = VALUES(expires_at),
+		created_by = VALUES(created_by), title = VALUES(title), tags = VALUES(tags),
+		notes = VALUES(notes), campaign = VALUES(campaign), utm_source = VALUES(utm_source),
+		utm_medium = VALUES(utm_medium), utm_campaign = VALUES(utm_campaign), utm_term = VALUES(utm_term),
+		utm_content = VALUES(utm_content), redirect = VALUES(redirect),
+		disabled = VALUES(disabled), deleted_at = VALUES(deleted_at), updated_at = VALUES(updated_at)`,
+		linkArgs(idKey, link)...)
+	return err
+}
+
+func (m *mysqlStorage) AddLink(idKey string, link *Link) (bool, error) {
+	res, err := m.db.Exec(`INSERT IGNORE`+insertLink+linkValues, linkArgs(idKey, link)...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (m *mysqlStorage) SetShortURL(idKey string, val string) error {
+	_, err := m.db.Exec(`INSERT INTO `+tableShort+` (short_key, id_key, created_at, updated_at)
+		VALUES (?, ?, UNIX_TIMESTAMP(), UNIX_TIMESTAMP())
+		ON DUPLICATE KEY UPDATE id_key = VALUES(id_key), updated_at = VALUES(updated_at)`, idKey, val)
+	return err
+}
+
+func (m *mysqlStorage) GetShortURLs(idKeys []string) ([]string, error) {
+	if len(idKeys) == 0 {
+		return nil, nil
+	}
+
+	rows, err := m.db.Query(`SELECT short_key, id_key FROM `+tableShort+` WHERE short_key IN (`+
+		placeholders(len(idKeys))+`)`, stringArgs(idKeys)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]string{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		found[k] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	vals := make([]string, len(idKeys))
+	for i, idKey := range idKeys {
+		vals[i] = found[idKey]
+	}
+	return vals, nil
+}
+
+// AddLinks inserts the links by a multi-row INSERT IGNORE per maxLinkRows.
+func (m *mysqlStorage) AddLinks(idKeys []string, links []*Link) ([]bool, error) {
+	added := make([]bool, 0, len(idKeys))
+	for start := 0; start < len(idKeys); start += maxLinkRows {
+		end := start + maxLinkRows
+		if end > len(idKeys) {
+			end = len(idKeys)
+		}
+		res, err := m.addLinks(idKeys[start:end], links[start:end])
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, res...)
+	}
+	return added, nil
+}
+
+// addLinks inserts the links at a time. INSERT IGNORE of many rows does not
+// tell which of them are ignored, so the links at the keys are read back if
+// any is, and the ones stored as given are added.
+func (m *mysqlStorage) addLinks(idKeys []string, links []*Link) ([]bool, error) {
+	values := make([]string, len(idKeys))
+	var args []interface{}
+	for i, idKey := range idKeys {
+		values[i] = linkValues
+		args = append(args, linkArgs(idKey, links[i])...)
+	}
+	res, err := m.db.Exec(`INSERT IGNORE`+insertLink+strings.Join(values, ", "), args...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	added := make([]bool, len(idKeys))
+	if n == int64(len(idKeys)) {
+		for i := range added {
+			added[i] = true
+		}
+		return added, nil
+	}
+
+	stored, err := m.GetLinks(idKeys)
+	if err != nil {
+		return nil, err
+	}
+	for i, link := range stored {
+		added[i] = link != nil && storedAs(link, links[i])
+	}
+	return added, nil
+}
+
+// storedAs reports whether the stored link is the one inserted, created_at is
+// compared unless it is set by the database.
+func storedAs(stored *Link, link *Link) bool {
+	var a, b UTM
+	if stored.UTM != nil {
+		a = *stored.UTM
+	}
+	if link.UTM != nil {
+		b = *link.UTM
+	}
+	return stored.LongURL == link.LongURL && stored.ExpiresAt == link.ExpiresAt &&
+		(link.CreatedAt == 0 || stored.CreatedAt == link.CreatedAt) && stored.CreatedBy == link.CreatedBy &&
+		stored.Title == link.Title && encodeTags(stored.Tags) == encodeTags(link.Tags) &&
+		stored.Notes == link.Notes && stored.Campaign == link.Campaign && a == b &&
+		stored.Redirect == link.Redirect && stored.Disabled == link.Disabled && stored.DeletedAt == link.DeletedAt
+}
+
+func (m *mysqlStorage) SetShortURLs(idKeys []string, vals []string) error {
+	if len(idKeys) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(idKeys))
+	args := make([]interface{}, 0, len(idKeys)*2)
+	for i, idKey := range idKeys {
+		values = append(values, "(?, ?, UNIX_TIMESTAMP(), UNIX_TIMESTAMP())")
+		args = append(args, idKey, vals[i])
+	}
+	_, err := m.db.Exec(`INSERT INTO `+tableShort+` (short_key, id_key, created_at, updated_at) VALUES `+strings.Join(values, ", ")+`
+		ON DUPLICATE KEY UPDATE id_key = VALUES(id_key), updated_at = VALUES(updated_at)`, args...)
+	return err
 }
 
-// GenerateShortURL generate the short URL by long URL
-func (d *d) generateShortURL() {
+func (m *mysqlStorage) DelShortURL(idKey string) error {
+	_, err := m.db.Exec(`DELETE FROM `+tableShort+` WHERE short_key = ?`, idKey)
+	return err
+}
+
+func (m *mysqlStorage) IncrClicks(idKey string, n int64) error {
+	_, err := m.db.Exec(`INSERT INTO `+tableClick+` (id_key, clicks, updated_at) VALUES (?, ?, UNIX_TIMESTAMP())
+		ON DUPLICATE KEY UPDATE clicks = clicks + VALUES(clicks), updated_at = VALUES(updated_at)`, idKey, n)
+	return err
+}
+
+func (m *mysqlStorage) GetClicks(idKey string) (int64, error) {
+	var clicks int64
+	row := m.db.QueryRow(`SELECT clicks FROM `+tableClick+` WHERE id_key = ?`, idKey)
+	err := row.Scan(&clicks)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return clicks, err
+}
+
+// maxEventRows is the max rows of an insert, for the limit of placeholders.
+const maxEventRows = 1000
+
+// AddClickEvents inserts the chunks of the events in a transaction, so the
+// events are not inserted twice when a failed call is retried.
+func (m *mysqlStorage) AddClickEvents(clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	for len(clicks) > 0 {
+		n := len(clicks)
+		if n > maxEventRows {
+			n = maxEventRows
+		}
+		if err := addClickEvents(tx, clicks[:n]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		clicks = clicks[n:]
+	}
+	return tx.Commit()
+}
+
+func addClickEvents(tx *sql.Tx, clicks []Click) error {
+	values := make([]string, 0, len(clicks))
+	args := make([]interface{}, 0, len(clicks)*8)
+	for i := range clicks {
+		c := &clicks[i]
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, c.ShortCode, c.Time.UTC(), truncate(c.Referrer, 255), truncate(c.UserAgent, 512),
+			c.Browser, c.Device, c.Country, c.IPHash)
+	}
+
+	_, err := tx.Exec(`INSERT INTO `+tableEvent+` (id_key, clicked_at, referrer, user_agent, browser, device, country, ip_hash)
+		VALUES `+strings.Join(values, ", "), args...)
+	return err
+}
+
+func (m *mysqlStorage) GetStats(idKey string) (*Stats, error) {
+	stats := newStats()
+
+	row := m.db.QueryRow(`SELECT COUNT(DISTINCT ip_hash) FROM `+tableEvent+` WHERE id_key = ? AND ip_hash != ''`, idKey)
+	if err := row.Scan(&stats.Visitors); err != nil {
+		return nil, err
+	}
+
+	groups := []struct {
+		expr string
+		m    map[string]int64
+	}{
+		{"DATE_FORMAT(clicked_at, '%Y-%m-%d')", stats.Days},
+		{"DATE_FORMAT(clicked_at, '%Y-%m-%dT%H')", stats.Hours},
+		{"SUBSTRING_INDEX(SUBSTRING_INDEX(SUBSTRING_INDEX(referrer, '/', 3), '/', -1), ':', 1)", stats.Referrers},
+		{"browser", stats.Browsers},
+		{"device", stats.Devices},
+		{"country", stats.Countries},
+	}
+	for _, g := range groups {
+		if err := m.groupClicks(idKey, g.expr, g.m); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+func (m *mysqlStorage) groupClicks(idKey string, expr string, result map[string]int64) error {
+	rows, err := m.db.Query(`SELECT `+expr+` AS k, COUNT(*) FROM `+tableEvent+` WHERE id_key = ? GROUP BY k`, idKey)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k string
+		var n int64
+		if err := rows.Scan(&k, &n); err != nil {
+			return err
+		}
+		result[k] = n
+	}
+	return rows.Err()
+}
+
+func (m *mysqlStorage) ListLinks(opts ListOptions) ([]*Entry, error) {
+	sortExpr := "l.created_at"
+	if opts.Sort == SortClicks {
+		sortExpr = "IFNULL(c.clicks, 0)"
+	}
+
+	where := []string{"l.deleted_at = 0"}
+	var args []interface{}
+	if opts.Tag != "" {
+		// tags is a JSON array, so the tag is matched with its quotes
+		tag := encodeTags([]string{opts.Tag})
+		where = append(where, "l.tags LIKE ?")
+		args = append(args, "%"+escapeLike(tag[1:len(tag)-1])+"%")
+	}
+	if opts.CreatedBy != "" {
+		where = append(where, "l.created_by = ?")
+		args = append(args, opts.CreatedBy)
+	}
+	if opts.CreatedAfter > 0 {
+		where = append(where, "l.created_at >= ?")
+		args = append(args, opts.CreatedAfter)
+	}
+	if opts.CreatedBefore > 0 {
+		where = append(where, "l.created_at < ?")
+		args = append(args, opts.CreatedBefore)
+	}
+	if opts.LongURL != "" {
+		where = append(where, "l.long_url LIKE ?")
+		args = append(args, "%"+escapeLike(opts.LongURL)+"%")
+	}
+	if opts.ShortHost != nil {
+		if *opts.ShortHost == "" {
+			where = append(where, "l.id_key NOT LIKE '%/%'")
+		} else {
+			where = append(where, "l.id_key LIKE ?")
+			args = append(args, escapeLike(*opts.ShortHost)+"/%")
+		}
+	}
+	if opts.Domain != "" {
+		// the long URLs are normalized as scheme://host[:port]/path
+		host := "SUBSTRING_INDEX(SUBSTRING_INDEX(SUBSTRING_INDEX(l.long_url, '/', 3), '/', -1), ':', 1)"
+		where = append(where, "("+host+" = ? OR "+host+" LIKE ?)")
+		args = append(args, opts.Domain, "%."+escapeLike(opts.Domain))
+	}
+
+	order, cmp := "ASC", ">"
+	if opts.Desc {
+		order, cmp = "DESC", "<"
+	}
+	if a := opts.After; a != nil {
+		where = append(where, "("+sortExpr+" "+cmp+" ? OR ("+sortExpr+" = ? AND l.id_key "+cmp+" ?))")
+		args = append(args, a.Value, a.Value, a.IDKey)
+	}
+
+	query := `SELECT ` + linkColumns + `, l.id_key, IFNULL(c.clicks, 0) FROM ` + tableLong + ` l
+		LEFT JOIN ` + tableClick + ` c ON c.id_key = l.id_key
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + sortExpr + ` ` + order + `, l.id_key ` + order
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit)
+	}
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*Entry
+	for rows.Next() {
+		e := &Entry{}
+		if e.Link, err = scanLink(rows, &e.IDKey, &e.Clicks); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (m *mysqlStorage) GetAPIKey(id string) (*APIKey, error) {
+	var scopes string
+	key := &APIKey{}
+	row := m.db.QueryRow(`SELECT id, hash, name, scopes, domain, created_at, created_by, revoked_at FROM `+tableKey+`
+		WHERE id = ?`, id)
+	err := row.Scan(&key.ID, &key.Hash, &key.Name, &scopes, &key.Domain, &key.CreatedAt, &key.CreatedBy, &key.RevokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (m *mysqlStorage) SetAPIKey(key *APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(`INSERT INTO `+tableKey+` (id, hash, name, scopes, domain, created_at, created_by, revoked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE hash = VALUES(hash), name = VALUES(name), scopes = VALUES(scopes),
+		domain = VALUES(domain), revoked_at = VALUES(revoked_at)`,
+		key.ID, key.Hash, key.Name, string(scopes), key.Domain, key.CreatedAt, key.CreatedBy, key.RevokedAt)
+	return err
+}
+
+// placeholders returns n placeholders separated by commas.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func stringArgs(vals []string) []interface{} {
+	args := make([]interface{}, len(vals))
+	for i, v := range vals {
+		args[i] = v
+	}
+	return args
+}
+
+// escapeLike escapes the wildcards of LIKE in the string.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
 
+// truncate cuts the string to at most n bytes without breaking characters,
+// for the limit of the columns.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
@@ -2,6 +2,8 @@ package dao
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/go-redis/redis"
 )
@@ -12,27 +14,52 @@ const (
 	cacheIDKey    = cachePre + "id"
 	cacheShortKey = cachePre + "short:%s"
 	cacheLongKey  = cachePre + "long:%s"
+	cacheClickKey = cachePre + "clicks:%s"
+	cacheStatsKey = cachePre + "stats:%s:%s"
+	cacheKeyKey   = cachePre + "apikey:%s"
 
 	// cache ttl
 	cacheTTL = 0
 
+	// expired links are kept for a while, so they are told from the unknown
+	cacheExpiredTTL = 30 * 24 * time.Hour
+
+	// the links expired before the while are kept for a minute, a ttl which
+	// is not positive would keep them forever
+	cacheMinTTL = time.Minute
+
 	// default ID
 	defaultID = 10000
 )
 
-var _r r
+// NewRedisStorage returns a Storage backed by redis
+func NewRedisStorage(client *redis.Client) Storage {
+	return &redisStorage{client: client}
+}
+
+type redisStorage struct {
+	client *redis.Client
+}
 
-type r struct {
+func (r *redisStorage) linkTTL(link *Link) time.Duration {
+	if link.ExpiresAt == 0 {
+		return cacheTTL
+	}
+	ttl := time.Until(time.Unix(link.ExpiresAt, 0)) + cacheExpiredTTL
+	if ttl < cacheMinTTL {
+		return cacheMinTTL
+	}
+	return ttl
 }
 
-func (r) generateID() (int64, error) {
+func (r *redisStorage) GenerateID() (int64, error) {
 	key := cacheIDKey
-	val, err := re.Incr(key).Result()
+	val, err := r.client.Incr(key).Result()
 
 	// first access
 	if val == 1 {
 		val = defaultID
-		_, err = re.Set(key, val, 0).Result()
+		_, err = r.client.Set(key, val, 0).Result()
 		if err != nil {
 			return 0, err
 		}
@@ -41,32 +68,323 @@ func (r) generateID() (int64, error) {
 	return val, err
 }
 
-func (r) getLongURL(idKey string) (string, error) {
+// GenerateIDs takes n IDs by a single INCRBY.
+func (r *redisStorage) GenerateIDs(n int) ([]int64, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	key := cacheIDKey
+	last, err := r.client.IncrBy(key, int64(n)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	// first access
+	if last == int64(n) {
+		last = defaultID + int64(n) - 1
+		if _, err := r.client.Set(key, last, 0).Result(); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = last - int64(n-1-i)
+	}
+	return ids, nil
+}
+
+func (r *redisStorage) GetLink(idKey string) (*Link, error) {
 	key := fmt.Sprintf(cacheLongKey, idKey)
-	val, err := re.Get(key).Result()
+	link := &Link{}
+	err := r.client.Get(key).Scan(link)
 	if err == redis.Nil {
-		err = nil
+		return nil, nil
 	}
-	return val, err
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
 }
 
-func (r) getShortURL(idKey string) (string, error) {
+func (r *redisStorage) GetLinks(idKeys []string) ([]*Link, error) {
+	if len(idKeys) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(idKeys))
+	for i, idKey := range idKeys {
+		keys[i] = fmt.Sprintf(cacheLongKey, idKey)
+	}
+	vals, err := r.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*Link, len(vals))
+	for i, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			continue
+		}
+		link := &Link{}
+		if err := link.UnmarshalBinary([]byte(s)); err != nil {
+			return nil, err
+		}
+		links[i] = link
+	}
+	return links, nil
+}
+
+func (r *redisStorage) GetShortURL(idKey string) (string, error) {
 	k := fmt.Sprintf(cacheShortKey, idKey)
-	v, err := re.Get(k).Result()
+	v, err := r.client.Get(k).Result()
 	if err == redis.Nil {
 		err = nil
 	}
 	return v, err
 }
 
-func (r) setLongURL(idKey string, val string) error {
+func (r *redisStorage) SetLink(idKey string, link *Link) error {
+	key := fmt.Sprintf(cacheLongKey, idKey)
+	_, err := r.client.Set(key, link, r.linkTTL(link)).Result()
+	return err
+}
+
+func (r *redisStorage) AddLink(idKey string, link *Link) (bool, error) {
+	key := fmt.Sprintf(cacheLongKey, idKey)
+	return r.client.SetNX(key, link, r.linkTTL(link)).Result()
+}
+
+// AddLinks sets the links by SETNX in a single pipeline.
+func (r *redisStorage) AddLinks(idKeys []string, links []*Link) ([]bool, error) {
+	if len(idKeys) == 0 {
+		return nil, nil
+	}
+
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(idKeys))
+	for i, idKey := range idKeys {
+		cmds[i] = pipe.SetNX(fmt.Sprintf(cacheLongKey, idKey), links[i], r.linkTTL(links[i]))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+
+	added := make([]bool, len(cmds))
+	for i, cmd := range cmds {
+		added[i] = cmd.Val()
+	}
+	return added, nil
+}
+
+func (r *redisStorage) DelLink(idKey string) error {
 	key := fmt.Sprintf(cacheLongKey, idKey)
-	_, err := re.Set(key, val, cacheTTL).Result()
+	_, err := r.client.Del(key).Result()
 	return err
 }
 
-func (r) setShortURL(idKey string, val string) error {
+func (r *redisStorage) SetShortURL(idKey string, val string) error {
 	key := fmt.Sprintf(cacheShortKey, idKey)
-	_, err := re.Set(key, val, cacheTTL).Result()
+	_, err := r.client.Set(key, val, cacheTTL).Result()
+	return err
+}
+
+func (r *redisStorage) GetShortURLs(idKeys []string) ([]string, error) {
+	if len(idKeys) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(idKeys))
+	for i, idKey := range idKeys {
+		keys[i] = fmt.Sprintf(cacheShortKey, idKey)
+	}
+	vals, err := r.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, len(vals))
+	for i, val := range vals {
+		res[i], _ = val.(string)
+	}
+	return res, nil
+}
+
+// SetShortURLs sets the short codes by MSET.
+func (r *redisStorage) SetShortURLs(idKeys []string, vals []string) error {
+	if len(idKeys) == 0 {
+		return nil
+	}
+
+	pairs := make([]interface{}, 0, 2*len(idKeys))
+	for i, idKey := range idKeys {
+		pairs = append(pairs, fmt.Sprintf(cacheShortKey, idKey), vals[i])
+	}
+	_, err := r.client.MSet(pairs...).Result()
+	return err
+}
+
+func (r *redisStorage) DelShortURL(idKey string) error {
+	key := fmt.Sprintf(cacheShortKey, idKey)
+	_, err := r.client.Del(key).Result()
+	return err
+}
+
+func (r *redisStorage) IncrClicks(idKey string, n int64) error {
+	key := fmt.Sprintf(cacheClickKey, idKey)
+	_, err := r.client.IncrBy(key, n).Result()
+	return err
+}
+
+func (r *redisStorage) GetClicks(idKey string) (int64, error) {
+	key := fmt.Sprintf(cacheClickKey, idKey)
+	val, err := r.client.Get(key).Int64()
+	if err == redis.Nil {
+		err = nil
+	}
+	return val, err
+}
+
+// The stats are kept in a hash per dimension, and the visitors are counted by
+// HyperLogLog.
+var statsDimensions = []string{"day", "hour", "referrer", "browser", "device", "country"}
+
+// AddClickEvents counts the events in a MULTI, so the events are not counted
+// twice when a failed call is retried.
+func (r *redisStorage) AddClickEvents(clicks []Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	pipe := r.client.TxPipeline()
+	for i := range clicks {
+		c := &clicks[i]
+		t := c.Time.UTC()
+		fields := []string{t.Format(statsDayLayout), t.Format(statsHourLayout), referrerHost(c.Referrer), c.Browser, c.Device, c.Country}
+		for j, dimension := range statsDimensions {
+			pipe.HIncrBy(fmt.Sprintf(cacheStatsKey, c.ShortCode, dimension), fields[j], 1)
+		}
+		if c.IPHash != "" {
+			pipe.PFAdd(fmt.Sprintf(cacheStatsKey, c.ShortCode, "uv"), c.IPHash)
+		}
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+func (r *redisStorage) GetStats(idKey string) (*Stats, error) {
+	pipe := r.client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(statsDimensions))
+	for i, dimension := range statsDimensions {
+		cmds[i] = pipe.HGetAll(fmt.Sprintf(cacheStatsKey, idKey, dimension))
+	}
+	uv := pipe.PFCount(fmt.Sprintf(cacheStatsKey, idKey, "uv"))
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	stats := newStats()
+	stats.Visitors = uv.Val()
+	maps := []map[string]int64{stats.Days, stats.Hours, stats.Referrers, stats.Browsers, stats.Devices, stats.Countries}
+	for i, cmd := range cmds {
+		for k, v := range cmd.Val() {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			maps[i][k] = n
+		}
+	}
+	return stats, nil
+}
+
+// listScanCount is the hint of keys scanned at a time by ListLinks.
+const listScanCount = 1000
+
+// ListLinks scans all of the links, it is meant for the admin and costs
+// O(N) of the links.
+func (r *redisStorage) ListLinks(opts ListOptions) ([]*Entry, error) {
+	var entries []*Entry
+	var cursor uint64
+	prefix := fmt.Sprintf(cacheLongKey, "")
+	for {
+		keys, next, err := r.client.Scan(cursor, prefix+"*", listScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		batch, err := r.listEntries(keys, prefix, &opts)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, batch...)
+
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return opts.page(entries), nil
+}
+
+func (r *redisStorage) listEntries(keys []string, prefix string, opts *ListOptions) ([]*Entry, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	pipe := r.client.Pipeline()
+	links := make([]*redis.StringCmd, len(keys))
+	clicks := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		idKey := key[len(prefix):]
+		links[i] = pipe.Get(key)
+		clicks[i] = pipe.Get(fmt.Sprintf(cacheClickKey, idKey))
+	}
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for i, key := range keys {
+		link := &Link{}
+		if err := links[i].Scan(link); err != nil {
+			// expired between SCAN and GET
+			if err == redis.Nil {
+				continue
+			}
+			return nil, err
+		}
+		idKey := key[len(prefix):]
+		if !opts.match(idKey, link) {
+			continue
+		}
+
+		n, err := clicks[i].Int64()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		entries = append(entries, &Entry{IDKey: idKey, Link: link, Clicks: n})
+	}
+	return entries, nil
+}
+
+func (r *redisStorage) GetAPIKey(id string) (*APIKey, error) {
+	k := fmt.Sprintf(cacheKeyKey, id)
+	key := &APIKey{}
+	err := r.client.Get(k).Scan(key)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *redisStorage) SetAPIKey(key *APIKey) error {
+	k := fmt.Sprintf(cacheKeyKey, key.ID)
+	_, err := r.client.Set(k, key, cacheTTL).Result()
 	return err
 }
@@ -2,10 +2,14 @@ package endpoint
 
 import (
 	"context"
+	"time"
 
 	kitendpoint "github.com/go-kit/kit/endpoint"
 
+	"github.com/WiFeng/short-url/pkg/analytics"
+	"github.com/WiFeng/short-url/pkg/auth"
 	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/ratelimit"
 	"github.com/WiFeng/short-url/pkg/service"
 )
 
@@ -13,24 +17,64 @@ import (
 // meant to be used as a helper struct, to collect all of the endpoints into a
 // single parameter.
 type Endpoints struct {
-	CreateEndpoint   kitendpoint.Endpoint
-	QueryEndpoint    kitendpoint.Endpoint
-	QueryAdvEndpoint kitendpoint.Endpoint
+	CreateEndpoint      kitendpoint.Endpoint
+	CreateBatchEndpoint kitendpoint.Endpoint
+	QueryEndpoint       kitendpoint.Endpoint
+	QueryAdvEndpoint    kitendpoint.Endpoint
+	UpdateEndpoint      kitendpoint.Endpoint
+	DeleteEndpoint      kitendpoint.Endpoint
+	ListEndpoint        kitendpoint.Endpoint
+	ClicksEndpoint      kitendpoint.Endpoint
+	StatsEndpoint       kitendpoint.Endpoint
+	GroupStatsEndpoint  kitendpoint.Endpoint
+	QRCodeEndpoint      kitendpoint.Endpoint
+	QRCodeAdvEndpoint   kitendpoint.Endpoint
+
+	IssueKeyEndpoint  kitendpoint.Endpoint
+	RevokeKeyEndpoint kitendpoint.Endpoint
 }
 
 // New returns a Endpoints that wraps the provided server, and wires in all of the
-// expected endpoint middlewares via the various parameters.
-func New(s service.Service, logger log.Logger) Endpoints {
+// expected endpoint middlewares via the various parameters. The clicks of
+// redirects are recorded into the pipeline, if it is not nil. The admin
+// endpoints require the callers authenticated by authn to have the scopes,
+// if it is not nil. The creates, batch creates and redirects are rate limited
+// by the limits, the public qr codes are limited like the redirects.
+func New(s service.Service, pipeline *analytics.Pipeline, authn auth.Authenticator, limits ratelimit.Limits, logger log.Logger) Endpoints {
+	authorize := func(scope string) kitendpoint.Middleware {
+		if authn == nil {
+			return nop
+		}
+		return AuthMiddleware(authn, scope)
+	}
+	limit := func(limiter ratelimit.Limiter) kitendpoint.Middleware {
+		if limiter == nil {
+			return nop
+		}
+		return RateLimitMiddleware(limiter)
+	}
+
 	var createEndpoint kitendpoint.Endpoint
 	{
 		createEndpoint = MakeCreateEndpoint(s)
+		createEndpoint = limit(limits.Create)(createEndpoint)
+		createEndpoint = authorize(auth.ScopeWrite)(createEndpoint)
 		// createEndpoint = LoggingMiddleware(log.With(logger, "method", "Create"))(createEndpoint)
 		createEndpoint = LoggingMiddleware(logger)(createEndpoint)
 	}
 
+	var createBatchEndpoint kitendpoint.Endpoint
+	{
+		createBatchEndpoint = MakeCreateBatchEndpoint(s)
+		createBatchEndpoint = limit(limits.Batch)(createBatchEndpoint)
+		createBatchEndpoint = authorize(auth.ScopeWrite)(createBatchEndpoint)
+		createBatchEndpoint = LoggingMiddleware(logger)(createBatchEndpoint)
+	}
+
 	var queryEndpoint kitendpoint.Endpoint
 	{
 		queryEndpoint = MakeQueyrEndpoint(s)
+		queryEndpoint = authorize(auth.ScopeRead)(queryEndpoint)
 		// queryEndpoint = LoggingMiddleware(log.With(logger, "method", "Query"))(queryEndpoint)
 		queryEndpoint = LoggingMiddleware(logger)(queryEndpoint)
 	}
@@ -38,32 +82,303 @@ func New(s service.Service, logger log.Logger) Endpoints {
 	var queryAdvEndpoint kitendpoint.Endpoint
 	{
 		queryAdvEndpoint = MakeQueyrAdvEndpoint(s)
+		queryAdvEndpoint = limit(limits.Redirect)(queryAdvEndpoint)
 		// queryAdvEndpoint = LoggingMiddleware(log.With(logger, "method", "QueryAdv"))(queryAdvEndpoint)
 		queryAdvEndpoint = LoggingMiddleware(logger)(queryAdvEndpoint)
+		if pipeline != nil {
+			queryAdvEndpoint = ClickMiddleware(pipeline)(queryAdvEndpoint)
+		}
+	}
+
+	var updateEndpoint kitendpoint.Endpoint
+	{
+		updateEndpoint = MakeUpdateEndpoint(s)
+		updateEndpoint = authorize(auth.ScopeWrite)(updateEndpoint)
+		updateEndpoint = LoggingMiddleware(logger)(updateEndpoint)
+	}
+
+	var deleteEndpoint kitendpoint.Endpoint
+	{
+		deleteEndpoint = MakeDeleteEndpoint(s)
+		deleteEndpoint = authorize(auth.ScopeWrite)(deleteEndpoint)
+		deleteEndpoint = LoggingMiddleware(logger)(deleteEndpoint)
+	}
+
+	var listEndpoint kitendpoint.Endpoint
+	{
+		listEndpoint = MakeListEndpoint(s)
+		listEndpoint = authorize(auth.ScopeRead)(listEndpoint)
+		listEndpoint = LoggingMiddleware(logger)(listEndpoint)
+	}
+
+	var clicksEndpoint kitendpoint.Endpoint
+	{
+		clicksEndpoint = MakeClicksEndpoint(s)
+		clicksEndpoint = authorize(auth.ScopeRead)(clicksEndpoint)
+		clicksEndpoint = LoggingMiddleware(logger)(clicksEndpoint)
+	}
+
+	var statsEndpoint kitendpoint.Endpoint
+	{
+		statsEndpoint = MakeStatsEndpoint(s)
+		statsEndpoint = authorize(auth.ScopeRead)(statsEndpoint)
+		statsEndpoint = LoggingMiddleware(logger)(statsEndpoint)
+	}
+
+	var groupStatsEndpoint kitendpoint.Endpoint
+	{
+		groupStatsEndpoint = MakeGroupStatsEndpoint(s)
+		groupStatsEndpoint = authorize(auth.ScopeRead)(groupStatsEndpoint)
+		groupStatsEndpoint = LoggingMiddleware(logger)(groupStatsEndpoint)
+	}
+
+	var qrCodeEndpoint kitendpoint.Endpoint
+	{
+		qrCodeEndpoint = MakeQRCodeEndpoint(s)
+		qrCodeEndpoint = authorize(auth.ScopeRead)(qrCodeEndpoint)
+		qrCodeEndpoint = LoggingMiddleware(logger)(qrCodeEndpoint)
+	}
+
+	var qrCodeAdvEndpoint kitendpoint.Endpoint
+	{
+		qrCodeAdvEndpoint = MakeQRCodeEndpoint(s)
+		qrCodeAdvEndpoint = limit(limits.Redirect)(qrCodeAdvEndpoint)
+		qrCodeAdvEndpoint = LoggingMiddleware(logger)(qrCodeAdvEndpoint)
+	}
+
+	var issueKeyEndpoint kitendpoint.Endpoint
+	{
+		issueKeyEndpoint = MakeIssueKeyEndpoint(s)
+		issueKeyEndpoint = authorize(auth.ScopeAdmin)(issueKeyEndpoint)
+		issueKeyEndpoint = LoggingMiddleware(logger)(issueKeyEndpoint)
+	}
+
+	var revokeKeyEndpoint kitendpoint.Endpoint
+	{
+		revokeKeyEndpoint = MakeRevokeKeyEndpoint(s)
+		revokeKeyEndpoint = authorize(auth.ScopeAdmin)(revokeKeyEndpoint)
+		revokeKeyEndpoint = LoggingMiddleware(logger)(revokeKeyEndpoint)
 	}
 
 	return Endpoints{
-		CreateEndpoint:   createEndpoint,
-		QueryEndpoint:    queryEndpoint,
-		QueryAdvEndpoint: queryAdvEndpoint,
+		CreateEndpoint:      createEndpoint,
+		CreateBatchEndpoint: createBatchEndpoint,
+		QueryEndpoint:       queryEndpoint,
+		QueryAdvEndpoint:    queryAdvEndpoint,
+		UpdateEndpoint:      updateEndpoint,
+		DeleteEndpoint:      deleteEndpoint,
+		ListEndpoint:        listEndpoint,
+		ClicksEndpoint:      clicksEndpoint,
+		StatsEndpoint:       statsEndpoint,
+		GroupStatsEndpoint:  groupStatsEndpoint,
+		QRCodeEndpoint:      qrCodeEndpoint,
+		QRCodeAdvEndpoint:   qrCodeAdvEndpoint,
+		IssueKeyEndpoint:    issueKeyEndpoint,
+		RevokeKeyEndpoint:   revokeKeyEndpoint,
+	}
+}
+
+func nop(next kitendpoint.Endpoint) kitendpoint.Endpoint { return next }
+
+// Create implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) Create(ctx context.Context, longURL string, opts service.CreateOptions) (string, error) {
+	resp, err := e.CreateEndpoint(ctx, newCreateRequest(longURL, opts))
+	if err != nil {
+		return "", err
+	}
+	response := resp.(CreateResponse)
+	return response.ShortURL, response.Err
+}
+
+// CreateBatch implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) CreateBatch(ctx context.Context, items []service.BatchItem) ([]service.BatchResult, error) {
+	req := CreateBatchRequest{Items: make([]CreateRequest, len(items))}
+	for i, item := range items {
+		req.Items[i] = newCreateRequest(item.LongURL, item.CreateOptions)
+	}
+
+	resp, err := e.CreateBatchEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(CreateBatchResponse)
+	if response.Err != nil {
+		return nil, response.Err
 	}
+
+	results := make([]service.BatchResult, len(response.Results))
+	for i, r := range response.Results {
+		results[i].ShortURL = r.ShortURL
+		if r.Code != "" {
+			results[i].Err = service.NewError(r.Code, r.Error)
+		}
+	}
+	return results, nil
+}
+
+// Query implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) Query(ctx context.Context, shortURL string) (*service.Link, error) {
+	resp, err := e.QueryEndpoint(ctx, QueryRequest{ShortURL: shortURL})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(QueryResponse)
+	return response.Link, response.Err
+}
+
+// Update implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) Update(ctx context.Context, shortURL string, opts service.UpdateOptions) (*service.Link, error) {
+	resp, err := e.UpdateEndpoint(ctx, UpdateRequest{
+		ShortURL: shortURL,
+		LongURL:  opts.LongURL,
+		Disabled: opts.Disabled,
+		Redirect: opts.Redirect,
+		Title:    opts.Title,
+		Tags:     opts.Tags,
+		Notes:    opts.Notes,
+		Campaign: opts.Campaign,
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(UpdateResponse)
+	return response.Link, response.Err
+}
+
+// Delete implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) Delete(ctx context.Context, shortURL string) error {
+	resp, err := e.DeleteEndpoint(ctx, QueryRequest{ShortURL: shortURL})
+	if err != nil {
+		return err
+	}
+	response := resp.(DeleteResponse)
+	return response.Err
+}
+
+// List implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) List(ctx context.Context, opts service.ListOptions) (*service.LinkList, error) {
+	resp, err := e.ListEndpoint(ctx, ListRequest(opts))
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(ListResponse)
+	return response.LinkList, response.Err
+}
+
+// Clicks implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) Clicks(ctx context.Context, shortURL string) (int64, error) {
+	resp, err := e.ClicksEndpoint(ctx, QueryRequest{ShortURL: shortURL})
+	if err != nil {
+		return 0, err
+	}
+	response := resp.(ClicksResponse)
+	return response.Clicks, response.Err
+}
+
+// Stats implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) Stats(ctx context.Context, shortURL string) (*service.Stats, error) {
+	resp, err := e.StatsEndpoint(ctx, QueryRequest{ShortURL: shortURL})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(StatsResponse)
+	return response.Stats, response.Err
+}
+
+// GroupStats implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) GroupStats(ctx context.Context, opts service.GroupOptions) ([]*service.Group, error) {
+	resp, err := e.GroupStatsEndpoint(ctx, GroupStatsRequest(opts))
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(GroupStatsResponse)
+	return response.Groups, response.Err
+}
+
+// QRCode implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) QRCode(ctx context.Context, shortURL string, opts service.QROptions) (*service.QRCode, error) {
+	resp, err := e.QRCodeEndpoint(ctx, QRCodeRequest{ShortURL: shortURL, QROptions: opts})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(QRCodeResponse)
+	return response.QRCode, response.Err
+}
+
+// IssueKey implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) IssueKey(ctx context.Context, name string, scopes []string, domain string) (*service.APIKey, error) {
+	resp, err := e.IssueKeyEndpoint(ctx, IssueKeyRequest{Name: name, Scopes: scopes, Domain: domain})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(KeyResponse)
+	return response.APIKey, response.Err
+}
+
+// RevokeKey implements the service interface, so Endpoints may be used as a
+// service. This is primarily useful in the context of a client library.
+func (e Endpoints) RevokeKey(ctx context.Context, id string) (*service.APIKey, error) {
+	resp, err := e.RevokeKeyEndpoint(ctx, RevokeKeyRequest{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	response := resp.(KeyResponse)
+	return response.APIKey, response.Err
 }
 
 // MakeCreateEndpoint constructs a Create endpoint wrapping the service.
 func MakeCreateEndpoint(s service.Service) kitendpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(CreateRequest)
-		shortURL, err := s.Create(ctx, req.LongURL)
+		shortURL, err := s.Create(ctx, req.LongURL, req.options())
 		return CreateResponse{ShortURL: shortURL, Err: err}, nil
 	}
 }
 
+// MakeCreateBatchEndpoint constructs a CreateBatch endpoint wrapping the
+// service.
+func MakeCreateBatchEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(CreateBatchRequest)
+		items := make([]service.BatchItem, len(req.Items))
+		for i, item := range req.Items {
+			items[i] = service.BatchItem{LongURL: item.LongURL, CreateOptions: item.options()}
+		}
+
+		results, err := s.CreateBatch(ctx, items)
+		if err != nil {
+			return CreateBatchResponse{Err: err}, nil
+		}
+
+		resp := CreateBatchResponse{Results: make([]CreateBatchResult, len(results))}
+		for i, r := range results {
+			resp.Results[i].ShortURL = r.ShortURL
+			if r.Err != nil {
+				resp.Results[i].Error = r.Err.Error()
+				resp.Results[i].Code = service.ErrorCode(r.Err)
+			}
+		}
+		return resp, nil
+	}
+}
+
 // MakeQueyrEndpoint constructs a Query endpoint wrapping the service.
 func MakeQueyrEndpoint(s service.Service) kitendpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(QueryRequest)
-		longURL, err := s.Query(ctx, req.ShortURL)
-		return QueryResponse{LongURL: longURL, Err: err}, nil
+		link, err := s.Query(ctx, req.ShortURL)
+		return QueryResponse{Link: link, Err: err}, nil
 	}
 }
 
@@ -71,20 +386,193 @@ func MakeQueyrEndpoint(s service.Service) kitendpoint.Endpoint {
 func MakeQueyrAdvEndpoint(s service.Service) kitendpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
 		req := request.(QueryRequest)
-		longURL, err := s.Query(ctx, req.ShortURL)
-		return QueryResponse{LongURL: longURL, Err: err}, nil
+		link, err := s.Query(ctx, req.ShortURL)
+		if err == nil && link.Blocked {
+			return QueryResponse{Err: service.ErrBlockedURL}, nil
+		}
+		return QueryResponse{Link: link, Err: err}, nil
+	}
+}
+
+// MakeUpdateEndpoint constructs a Update endpoint wrapping the service.
+func MakeUpdateEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(UpdateRequest)
+		link, err := s.Update(ctx, req.ShortURL, service.UpdateOptions{
+			LongURL:  req.LongURL,
+			Disabled: req.Disabled,
+			Redirect: req.Redirect,
+			Title:    req.Title,
+			Tags:     req.Tags,
+			Notes:    req.Notes,
+			Campaign: req.Campaign,
+		})
+		return UpdateResponse{Link: link, Err: err}, nil
+	}
+}
+
+// MakeDeleteEndpoint constructs a Delete endpoint wrapping the service.
+func MakeDeleteEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(QueryRequest)
+		err = s.Delete(ctx, req.ShortURL)
+		return DeleteResponse{Err: err}, nil
+	}
+}
+
+// MakeListEndpoint constructs a List endpoint wrapping the service.
+func MakeListEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(ListRequest)
+		list, err := s.List(ctx, service.ListOptions(req))
+		return ListResponse{LinkList: list, Err: err}, nil
+	}
+}
+
+// MakeClicksEndpoint constructs a Clicks endpoint wrapping the service.
+func MakeClicksEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(QueryRequest)
+		clicks, err := s.Clicks(ctx, req.ShortURL)
+		return ClicksResponse{Clicks: clicks, Err: err}, nil
+	}
+}
+
+// MakeStatsEndpoint constructs a Stats endpoint wrapping the service.
+func MakeStatsEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(QueryRequest)
+		stats, err := s.Stats(ctx, req.ShortURL)
+		return StatsResponse{Stats: stats, Err: err}, nil
+	}
+}
+
+// MakeGroupStatsEndpoint constructs a GroupStats endpoint wrapping the service.
+func MakeGroupStatsEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(GroupStatsRequest)
+		groups, err := s.GroupStats(ctx, service.GroupOptions(req))
+		return GroupStatsResponse{Groups: groups, Err: err}, nil
+	}
+}
+
+// MakeQRCodeEndpoint constructs a QRCode endpoint wrapping the service.
+func MakeQRCodeEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(QRCodeRequest)
+		code, err := s.QRCode(ctx, req.ShortURL, req.QROptions)
+		return QRCodeResponse{QRCode: code, Err: err}, nil
+	}
+}
+
+// MakeIssueKeyEndpoint constructs a IssueKey endpoint wrapping the service.
+func MakeIssueKeyEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(IssueKeyRequest)
+		key, err := s.IssueKey(ctx, req.Name, req.Scopes, req.Domain)
+		return KeyResponse{APIKey: key, Err: err}, nil
+	}
+}
+
+// MakeRevokeKeyEndpoint constructs a RevokeKey endpoint wrapping the service.
+func MakeRevokeKeyEndpoint(s service.Service) kitendpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+		req := request.(RevokeKeyRequest)
+		key, err := s.RevokeKey(ctx, req.ID)
+		return KeyResponse{APIKey: key, Err: err}, nil
 	}
 }
 
 // compile time assertions for our response types implementing endpoint.Failer.
 var (
 	_ kitendpoint.Failer = CreateResponse{}
+	_ kitendpoint.Failer = CreateBatchResponse{}
 	_ kitendpoint.Failer = QueryResponse{}
+	_ kitendpoint.Failer = UpdateResponse{}
+	_ kitendpoint.Failer = DeleteResponse{}
+	_ kitendpoint.Failer = ListResponse{}
+	_ kitendpoint.Failer = ClicksResponse{}
+	_ kitendpoint.Failer = StatsResponse{}
+	_ kitendpoint.Failer = GroupStatsResponse{}
+	_ kitendpoint.Failer = QRCodeResponse{}
+	_ kitendpoint.Failer = KeyResponse{}
 )
 
 // CreateRequest collects the request parameters for the Sum method.
 type CreateRequest struct {
 	LongURL string `json:"long_url"`
+	Alias   string `json:"alias,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+
+	// ExpiresIn is in seconds, and ExpiresAt is in RFC 3339.
+	ExpiresIn int64      `json:"expires_in,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	Redirect string `json:"redirect,omitempty"`
+
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+
+	CreatedBy string   `json:"created_by,omitempty"`
+	Title     string   `json:"title,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Notes     string   `json:"notes,omitempty"`
+	Campaign  string   `json:"campaign,omitempty"`
+}
+
+func newCreateRequest(longURL string, opts service.CreateOptions) CreateRequest {
+	req := CreateRequest{
+		LongURL:   longURL,
+		Alias:     opts.Alias,
+		Domain:    opts.Domain,
+		ExpiresIn: int64(opts.ExpiresIn / time.Second),
+		Redirect:  opts.Redirect,
+		CreatedBy: opts.CreatedBy,
+		Title:     opts.Title,
+		Tags:      opts.Tags,
+		Notes:     opts.Notes,
+		Campaign:  opts.Campaign,
+
+		UTMSource:   opts.UTM.Source,
+		UTMMedium:   opts.UTM.Medium,
+		UTMCampaign: opts.UTM.Campaign,
+		UTMTerm:     opts.UTM.Term,
+		UTMContent:  opts.UTM.Content,
+	}
+	if !opts.ExpiresAt.IsZero() {
+		req.ExpiresAt = &opts.ExpiresAt
+	}
+	return req
+}
+
+func (r CreateRequest) options() service.CreateOptions {
+	opts := service.CreateOptions{
+		Alias:     r.Alias,
+		Domain:    r.Domain,
+		ExpiresIn: time.Duration(r.ExpiresIn) * time.Second,
+		Redirect:  r.Redirect,
+		UTM: service.UTM{
+			Source:   r.UTMSource,
+			Medium:   r.UTMMedium,
+			Campaign: r.UTMCampaign,
+			Term:     r.UTMTerm,
+			Content:  r.UTMContent,
+		},
+		Metadata: service.Metadata{
+			CreatedBy: r.CreatedBy,
+			Title:     r.Title,
+			Tags:      r.Tags,
+			Notes:     r.Notes,
+			Campaign:  r.Campaign,
+		},
+	}
+	if r.ExpiresAt != nil {
+		opts.ExpiresAt = *r.ExpiresAt
+	}
+	return opts
 }
 
 // CreateResponse collects the response values for the Sum method.
@@ -96,6 +584,30 @@ type CreateResponse struct {
 // Failed implements endpoint.Failer.
 func (r CreateResponse) Failed() error { return r.Err }
 
+// CreateBatchRequest collects the request parameters for the CreateBatch
+// method.
+type CreateBatchRequest struct {
+	Items []CreateRequest `json:"items"`
+}
+
+// CreateBatchResult is the result of an item of the batch, the error is of
+// the item only.
+type CreateBatchResult struct {
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Code     string `json:"code,omitempty"`
+}
+
+// CreateBatchResponse collects the response values for the CreateBatch
+// method, the results are in the order of the items.
+type CreateBatchResponse struct {
+	Results []CreateBatchResult `json:"results"`
+	Err     error               `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r CreateBatchResponse) Failed() error { return r.Err }
+
 // QueryRequest collects the request parameters for the Concat method.
 type QueryRequest struct {
 	ShortURL string `json:"short_url"`
@@ -103,9 +615,120 @@ type QueryRequest struct {
 
 // QueryResponse collects the response values for the Concat method.
 type QueryResponse struct {
-	LongURL string `json:"long_url"`
-	Err     error  `json:"-"`
+	*service.Link
+	Err error `json:"-"`
 }
 
 // Failed implements endpoint.Failer.
 func (r QueryResponse) Failed() error { return r.Err }
+
+// UpdateRequest collects the request parameters for the Update method, the
+// absent fields are unchanged.
+type UpdateRequest struct {
+	ShortURL string    `json:"short_url"`
+	LongURL  *string   `json:"long_url,omitempty"`
+	Disabled *bool     `json:"disabled,omitempty"`
+	Redirect *string   `json:"redirect,omitempty"`
+	Title    *string   `json:"title,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"`
+	Notes    *string   `json:"notes,omitempty"`
+	Campaign *string   `json:"campaign,omitempty"`
+}
+
+// UpdateResponse collects the response values for the Update method.
+type UpdateResponse struct {
+	*service.Link
+	Err error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r UpdateResponse) Failed() error { return r.Err }
+
+// DeleteResponse collects the response values for the Delete method.
+type DeleteResponse struct {
+	Err error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r DeleteResponse) Failed() error { return r.Err }
+
+// ListRequest collects the request parameters for the List method.
+type ListRequest service.ListOptions
+
+// ListResponse collects the response values for the List method.
+type ListResponse struct {
+	*service.LinkList
+	Err error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ListResponse) Failed() error { return r.Err }
+
+// ClicksResponse collects the response values for the Clicks method.
+type ClicksResponse struct {
+	Clicks int64 `json:"clicks"`
+	Err    error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ClicksResponse) Failed() error { return r.Err }
+
+// StatsResponse collects the response values for the Stats method.
+type StatsResponse struct {
+	*service.Stats
+	Err error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r StatsResponse) Failed() error { return r.Err }
+
+// GroupStatsRequest collects the request parameters for the GroupStats
+// method.
+type GroupStatsRequest service.GroupOptions
+
+// GroupStatsResponse collects the response values for the GroupStats method.
+type GroupStatsResponse struct {
+	Groups []*service.Group `json:"groups"`
+	Err    error            `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r GroupStatsResponse) Failed() error { return r.Err }
+
+// QRCodeRequest collects the request parameters for the QRCode method.
+type QRCodeRequest struct {
+	ShortURL string
+	service.QROptions
+}
+
+// QRCodeResponse collects the response values for the QRCode method, the
+// image is encoded as is rather than JSON.
+type QRCodeResponse struct {
+	*service.QRCode
+	Err error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r QRCodeResponse) Failed() error { return r.Err }
+
+// IssueKeyRequest collects the request parameters for the IssueKey method.
+type IssueKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Domain string   `json:"domain,omitempty"`
+}
+
+// RevokeKeyRequest collects the request parameters for the RevokeKey method.
+type RevokeKeyRequest struct {
+	ID string `json:"id"`
+}
+
+// KeyResponse collects the response values for the IssueKey and RevokeKey
+// methods.
+type KeyResponse struct {
+	*service.APIKey
+	Err error `json:"-"`
+}
+
+// Failed implements endpoint.Failer.
+func (r KeyResponse) Failed() error { return r.Err }
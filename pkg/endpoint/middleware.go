@@ -2,11 +2,17 @@ package endpoint
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	kitendpoint "github.com/go-kit/kit/endpoint"
 
+	"github.com/WiFeng/short-url/pkg/analytics"
+	"github.com/WiFeng/short-url/pkg/auth"
 	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/ratelimit"
+	"github.com/WiFeng/short-url/pkg/service"
 )
 
 // LoggingMiddleware returns an endpoint middleware that logs the
@@ -21,3 +27,90 @@ func LoggingMiddleware(logger log.Logger) kitendpoint.Middleware {
 		}
 	}
 }
+
+// AuthMiddleware returns an endpoint middleware that authenticates the token
+// in the context, and requires the caller to have the scope. The caller is put
+// into the context for the service.
+func AuthMiddleware(authn auth.Authenticator, scope string) kitendpoint.Middleware {
+	return func(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			token := auth.TokenFromContext(ctx)
+			if token == "" {
+				return nil, service.ErrUnauthorized
+			}
+
+			p, err := authn.Authenticate(ctx, token)
+			if errors.Is(err, auth.ErrInvalidToken) {
+				return nil, fmt.Errorf("%w: %v", service.ErrUnauthorized, err)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !p.Allows(scope) {
+				return nil, fmt.Errorf("%w: %s scope is required", service.ErrForbidden, scope)
+			}
+
+			return next(auth.ContextWithPrincipal(ctx, p), request)
+		}
+	}
+}
+
+// RateLimitError is returned when the client is rate limited, it is a
+// service.ErrRateLimited with the wait for the next request.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", service.ErrRateLimited, e.RetryAfter)
+}
+
+// Unwrap makes RateLimitError a service.ErrRateLimited.
+func (e *RateLimitError) Unwrap() error {
+	return service.ErrRateLimited
+}
+
+// RateLimitMiddleware returns an endpoint middleware that limits the requests
+// of the client by the limiter. The authenticated callers are limited by their
+// subjects, others by their addresses. The requests are let through if the
+// limiter fails.
+func RateLimitMiddleware(limiter ratelimit.Limiter) kitendpoint.Middleware {
+	return func(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			key := "ip:" + ratelimit.ClientFromContext(ctx)
+			if p := auth.PrincipalFromContext(ctx); p != nil {
+				key = "sub:" + p.Subject
+			}
+
+			ok, retryAfter, err := limiter.Allow(key)
+			if err != nil {
+				log.Warnw(ctx, "rate limiter error", "key", key, "err", err)
+			}
+			if !ok {
+				return nil, &RateLimitError{RetryAfter: retryAfter}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// ClickMiddleware returns an endpoint middleware that records a click into
+// the pipeline for every successful redirect.
+func ClickMiddleware(pipeline *analytics.Pipeline) kitendpoint.Middleware {
+	return func(next kitendpoint.Endpoint) kitendpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			response, err = next(ctx, request)
+			if err != nil {
+				return response, err
+			}
+			if f, ok := response.(kitendpoint.Failer); ok && f.Failed() != nil {
+				return response, err
+			}
+
+			if resp, ok := response.(QueryResponse); ok && resp.Link != nil {
+				pipeline.Record(ctx, resp.Key)
+			}
+			return response, err
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Package filter checks the destinations of links against the domain lists,
+// the regex rules and the hash prefix list of the [filter] config, so the
+// service is not abused for phishing and malware.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+)
+
+// Filter checks the destinations. A nil Filter blocks nothing.
+type Filter struct {
+	blocklist []string
+	allowlist []string
+	rules     []*regexp.Regexp
+	prefixes  *hashPrefixes
+}
+
+// New returns the Filter of the [filter] config, the hash prefix file is read
+// here.
+func New(conf *config.Config) (*Filter, error) {
+	c := conf.Filter
+	f := &Filter{}
+
+	var err error
+	if f.blocklist, err = normalizeDomains(c.Blocklist); err != nil {
+		return nil, err
+	}
+	if f.allowlist, err = normalizeDomains(c.Allowlist); err != nil {
+		return nil, err
+	}
+
+	for _, rule := range c.Rules {
+		re, err := regexp.Compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("filter: rule %q: %v", rule, err)
+		}
+		f.rules = append(f.rules, re)
+	}
+
+	if c.HashPrefixFile != "" {
+		if f.prefixes, err = loadHashPrefixes(c.HashPrefixFile); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Check returns the reason as an error if the destination is blocked. The URL
+// is expected to be normalized by the service.
+func (f *Filter) Check(rawURL string) error {
+	if f == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(u.Hostname())
+
+	if d, ok := matchDomain(host, f.blocklist); ok {
+		return fmt.Errorf("domain %s is on the blocklist", d)
+	}
+	if _, ok := matchDomain(host, f.allowlist); len(f.allowlist) > 0 && !ok {
+		return fmt.Errorf("domain %s is not on the allowlist", host)
+	}
+	for _, re := range f.rules {
+		if re.MatchString(rawURL) {
+			return fmt.Errorf("matches the rule %s", re)
+		}
+	}
+	if f.prefixes.match(u) {
+		return errors.New("listed as unsafe")
+	}
+	return nil
+}
+
+// matchDomain returns the domain of the list which the host is, or is a
+// subdomain of.
+func matchDomain(host string, domains []string) (string, bool) {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// normalizeDomains lowercases the domains, and converts them to punycode like
+// the hosts of the normalized URLs. The leading "*." or "." is optional.
+func normalizeDomains(domains []string) ([]string, error) {
+	res := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*")
+		d = strings.Trim(d, ".")
+		if d == "" {
+			continue
+		}
+		ascii, err := idna.Lookup.ToASCII(d)
+		if err != nil {
+			return nil, fmt.Errorf("filter: domain %q: %v", d, err)
+		}
+		res = append(res, ascii)
+	}
+	return res, nil
+}
@@ -0,0 +1,148 @@
+package filter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+)
+
+func writePrefixes(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "prefixes.txt")
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// prefix returns the hex of the first n bytes of the sha256 of the expression.
+func prefix(expr string, n int) string {
+	sum := sha256.Sum256([]byte(expr))
+	return hex.EncodeToString(sum[:n])
+}
+
+func TestCheck(t *testing.T) {
+	conf := &config.Config{}
+	conf.Filter.Blocklist = []string{"Evil.example", "*.bad.example", "bücher.example"}
+	conf.Filter.Rules = []string{`\.exe$`, `(?i)/login\.php`}
+	conf.Filter.HashPrefixFile = writePrefixes(t,
+		"# unsafe expressions",
+		"",
+		prefix("malware.example/", 4),
+		"  "+prefix("phish.example/path/", sha256.Size)+"  ",
+	)
+	f, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://example.com/", false},
+		{"https://evil.example/", true},
+		{"https://www.evil.example/a", true},
+		{"https://notevil.example/", false},
+		{"https://bad.example/", true},
+		{"https://a.b.bad.example/", true},
+		{"https://xn--bcher-kva.example/", true},
+		{"https://example.com/setup.exe", true},
+		{"https://example.com/setup.exe.txt", false},
+		{"https://example.com/LOGIN.php?next=/", true},
+		{"https://malware.example/", true},
+		{"https://cdn.malware.example/any/path?q=1", true},
+		{"https://phish.example/path/deeper/page.html", true},
+		{"https://phish.example/other/", false},
+	}
+	for _, c := range cases {
+		if err := f.Check(c.url); (err != nil) != c.blocked {
+			t.Errorf("%s: got %v, want blocked %v", c.url, err, c.blocked)
+		}
+	}
+
+	var nilFilter *Filter
+	if err := nilFilter.Check("https://evil.example/"); err != nil {
+		t.Fatalf("got %v of a nil filter", err)
+	}
+}
+
+func TestCheckAllowlist(t *testing.T) {
+	conf := &config.Config{}
+	conf.Filter.Allowlist = []string{".example.com", "go.dev"}
+	conf.Filter.Blocklist = []string{"private.example.com"}
+	f, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url     string
+		blocked bool
+	}{
+		{"https://example.com/", false},
+		{"https://docs.example.com/a", false},
+		{"https://go.dev/", false},
+		{"https://pkg.go.dev/", false},
+		{"https://example.org/", true},
+		{"https://example.com.evil.org/", true},
+		{"https://notgo.dev/", true},
+		// the blocklist wins over the allowlist
+		{"https://private.example.com/", true},
+	}
+	for _, c := range cases {
+		if err := f.Check(c.url); (err != nil) != c.blocked {
+			t.Errorf("%s: got %v, want blocked %v", c.url, err, c.blocked)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		set  func(c *config.Config)
+	}{
+		{"rule", func(c *config.Config) { c.Filter.Rules = []string{"("} }},
+		{"domain", func(c *config.Config) { c.Filter.Blocklist = []string{"under_score.example"} }},
+		{"missing file", func(c *config.Config) { c.Filter.HashPrefixFile = filepath.Join(t.TempDir(), "nope") }},
+		{"not hex", func(c *config.Config) { c.Filter.HashPrefixFile = writePrefixes(t, "zzzzzzzz") }},
+		{"short prefix", func(c *config.Config) { c.Filter.HashPrefixFile = writePrefixes(t, "abcdef") }},
+		{"long prefix", func(c *config.Config) { c.Filter.HashPrefixFile = writePrefixes(t, prefix("a/", sha256.Size)+"00") }},
+	}
+	for _, c := range cases {
+		conf := &config.Config{}
+		c.set(conf)
+		if _, err := New(conf); err == nil {
+			t.Errorf("%s: got no error", c.name)
+		}
+	}
+}
+
+func TestExpressions(t *testing.T) {
+	cases := []struct {
+		url  string
+		want []string
+	}{
+		{"http://a.b.c/1/2.html?param=1", []string{
+			"a.b.c/1/2.html", "a.b.c/1/2.html?param=1", "a.b.c/", "a.b.c/1/",
+			"b.c/1/2.html", "b.c/1/2.html?param=1", "b.c/", "b.c/1/",
+		}},
+		{"http://192.168.0.1/", []string{"192.168.0.1/"}},
+		{"http://a.b.c.d.e.f.g/", []string{"a.b.c.d.e.f.g/", "c.d.e.f.g/", "d.e.f.g/", "e.f.g/", "f.g/"}},
+	}
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := expressions(u); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.url, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	// the lengths of the hash prefixes in bytes, like Safe Browsing
+	minPrefixLength = 4
+	maxPrefixLength = sha256.Size
+
+	// the numbers of the host suffixes and path prefixes of the expressions
+	maxHostSuffixes = 5
+	maxPathPrefixes = 6
+)
+
+// hashPrefixes is a local list of the sha256 prefixes of the unsafe URL
+// expressions, in the manner of the Safe Browsing Update API. There is no
+// full hash to confirm a match locally, so the full hashes or long prefixes
+// are preferred to avoid the false positives.
+type hashPrefixes struct {
+	// prefixes by their lengths
+	sets map[int]map[string]struct{}
+}
+
+// loadHashPrefixes reads the file of a hash prefix in hex per line, the empty
+// lines and the lines starting with # are skipped.
+func loadHashPrefixes(path string) (*hashPrefixes, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	defer file.Close()
+
+	h := &hashPrefixes{sets: map[int]map[string]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, err := hex.DecodeString(line)
+		if err != nil || len(prefix) < minPrefixLength || len(prefix) > maxPrefixLength {
+			return nil, fmt.Errorf("filter: %s:%d: invalid hash prefix", path, n)
+		}
+		set, ok := h.sets[len(prefix)]
+		if !ok {
+			set = map[string]struct{}{}
+			h.sets[len(prefix)] = set
+		}
+		set[string(prefix)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("filter: %v", err)
+	}
+	return h, nil
+}
+
+// match reports whether any expression of the URL has a listed hash prefix.
+func (h *hashPrefixes) match(u *url.URL) bool {
+	if h == nil || len(h.sets) == 0 {
+		return false
+	}
+	for _, expr := range expressions(u) {
+		sum := sha256.Sum256([]byte(expr))
+		for length, set := range h.sets {
+			if _, ok := set[string(sum[:length])]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expressions returns the host suffix and path prefix expressions of the URL,
+// e.g. "a.b.c/1/2.html?param=1" gets "a.b.c/1/2.html?param=1",
+// "a.b.c/1/2.html", "a.b.c/", "a.b.c/1/", "b.c/1/2.html?param=1" and so on.
+func expressions(u *url.URL) []string {
+	host := strings.Trim(strings.ToLower(u.Hostname()), ".")
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		labels := strings.Split(host, ".")
+		if len(labels) > maxHostSuffixes {
+			labels = labels[len(labels)-maxHostSuffixes:]
+		}
+		for i := 0; i < len(labels)-1; i++ {
+			suffix := strings.Join(labels[i:], ".")
+			if suffix != host {
+				hosts = append(hosts, suffix)
+			}
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	paths := []string{path}
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	prefix := "/"
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; len(paths) < maxPathPrefixes && i < len(segments); i++ {
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+		prefix += segments[i] + "/"
+	}
+
+	exprs := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			exprs = append(exprs, h+p)
+		}
+	}
+	return exprs
+}
@@ -0,0 +1,184 @@
+// Package qr renders the QR codes of short URLs as PNG or SVG images, in
+// process. The modules are encoded by go-qrcode, the images are drawn here so
+// the margin and the colors are configurable.
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// the formats of the images
+const (
+	PNG = "png"
+	SVG = "svg"
+)
+
+// ContentTypes are the content types of the formats.
+var ContentTypes = map[string]string{
+	PNG: "image/png",
+	SVG: "image/svg+xml",
+}
+
+// levels are the error correction levels, about 7%, 15%, 25% and 30% of the
+// code may be restored.
+var levels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// Options are the options of the images, they are expected to be checked by
+// Check.
+type Options struct {
+	// Format is PNG or SVG.
+	Format string
+
+	// Size is the width and the height of the image in pixels. The image is
+	// larger if the size is less than the modules and the margin.
+	Size int
+
+	// Level is the error correction level, one of L, M, Q and H.
+	Level string
+
+	// Margin is the quiet zone around the code in modules, 4 is required by
+	// the spec.
+	Margin int
+
+	// Foreground and Background are the colors in #rgb or #rrggbb.
+	Foreground string
+	Background string
+}
+
+// Check returns the reason as an error if the options are invalid.
+func (o Options) Check() error {
+	if _, ok := ContentTypes[o.Format]; !ok {
+		return fmt.Errorf("unknown format %q", o.Format)
+	}
+	if _, ok := levels[o.Level]; !ok {
+		return fmt.Errorf("unknown level %q", o.Level)
+	}
+	if o.Size <= 0 {
+		return fmt.Errorf("invalid size %d", o.Size)
+	}
+	if o.Margin < 0 {
+		return fmt.Errorf("invalid margin %d", o.Margin)
+	}
+	if _, err := ParseColor(o.Foreground); err != nil {
+		return err
+	}
+	if _, err := ParseColor(o.Background); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Encode returns the image of the QR code of the content.
+func Encode(content string, o Options) ([]byte, error) {
+	if err := o.Check(); err != nil {
+		return nil, err
+	}
+	code, err := qrcode.New(content, levels[o.Level])
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	modules := code.Bitmap()
+
+	fg, _ := ParseColor(o.Foreground)
+	bg, _ := ParseColor(o.Background)
+	if o.Format == SVG {
+		return encodeSVG(modules, o, fg, bg), nil
+	}
+	return encodePNG(modules, o, fg, bg)
+}
+
+// layout returns the pixels of a module and the offset of the code, which is
+// centered in the image of the size.
+func layout(modules [][]bool, o Options) (size int, scale int, offset int) {
+	n := len(modules) + 2*o.Margin
+	scale = o.Size / n
+	if scale < 1 {
+		scale = 1
+	}
+	size = o.Size
+	if size < n*scale {
+		size = n * scale
+	}
+	offset = (size-n*scale)/2 + o.Margin*scale
+	return size, scale, offset
+}
+
+func encodePNG(modules [][]bool, o Options, fg color.RGBA, bg color.RGBA) ([]byte, error) {
+	size, scale, offset := layout(modules, o)
+
+	// index 0 is the background, so the image is filled with it
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			top, left := offset+y*scale, offset+x*scale
+			for py := top; py < top+scale; py++ {
+				i := img.PixOffset(left, py)
+				for px := 0; px < scale; px++ {
+					img.Pix[i+px] = 1
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeSVG draws the modules in a path of the unit squares, the view box is
+// in pixels of the same layout as the PNG.
+func encodeSVG(modules [][]bool, o Options, fg color.RGBA, bg color.RGBA) []byte {
+	size, scale, offset := layout(modules, o)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, size, size)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(bg))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(fg))
+	for y, row := range modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh%dv%dh-%dz", offset+x*scale, offset+y*scale, scale, scale, scale)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// ParseColor parses the color in #rgb or #rrggbb.
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,157 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const content = "http://sh.url/2bI"
+
+// modules returns the modules of the content like Encode.
+func modules(t *testing.T, level string) [][]bool {
+	code, err := qrcode.New(content, levels[level])
+	if err != nil {
+		t.Fatal(err)
+	}
+	code.DisableBorder = true
+	return code.Bitmap()
+}
+
+func validOptions() Options {
+	return Options{Format: PNG, Size: 256, Level: "M", Margin: 4, Foreground: "#000", Background: "#ffffff"}
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		name  string
+		set   func(o *Options)
+		valid bool
+	}{
+		{"valid", func(o *Options) {}, true},
+		{"svg", func(o *Options) { o.Format = SVG }, true},
+		{"no margin", func(o *Options) { o.Margin = 0 }, true},
+		{"level H", func(o *Options) { o.Level = "H" }, true},
+		{"gif", func(o *Options) { o.Format = "gif" }, false},
+		{"upper format", func(o *Options) { o.Format = "PNG" }, false},
+		{"level X", func(o *Options) { o.Level = "X" }, false},
+		{"no level", func(o *Options) { o.Level = "" }, false},
+		{"zero size", func(o *Options) { o.Size = 0 }, false},
+		{"negative size", func(o *Options) { o.Size = -1 }, false},
+		{"negative margin", func(o *Options) { o.Margin = -1 }, false},
+		{"foreground", func(o *Options) { o.Foreground = "#12345" }, false},
+		{"background", func(o *Options) { o.Background = "white" }, false},
+	}
+	for _, c := range cases {
+		o := validOptions()
+		c.set(&o)
+		if err := o.Check(); (err == nil) != c.valid {
+			t.Errorf("%s: got %v, want valid %v", c.name, err, c.valid)
+		}
+		if _, err := Encode(content, o); (err == nil) != c.valid {
+			t.Errorf("%s: got %v of Encode, want valid %v", c.name, err, c.valid)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	cases := []struct {
+		s    string
+		want color.RGBA
+		ok   bool
+	}{
+		{"#000", color.RGBA{A: 0xff}, true},
+		{"#fA0", color.RGBA{R: 0xff, G: 0xaa, A: 0xff}, true},
+		{"#1e90ff", color.RGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0xff}, true},
+		{"1e90ff", color.RGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0xff}, true},
+		{"", color.RGBA{}, false},
+		{"#12", color.RGBA{}, false},
+		{"#1e90ff00", color.RGBA{}, false},
+		{"#ggg", color.RGBA{}, false},
+	}
+	for _, c := range cases {
+		got, err := ParseColor(c.s)
+		if (err == nil) != c.ok || got != c.want {
+			t.Errorf("%q: got %v, %v, want %v", c.s, got, err, c.want)
+		}
+	}
+}
+
+func TestEncodePNG(t *testing.T) {
+	o := validOptions()
+	o.Foreground = "#1e90ff"
+	data, err := Encode(content, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+		t.Fatalf("got bounds %v, want 256x256", b)
+	}
+
+	fg := color.RGBAModel.Convert(color.RGBA{R: 0x1e, G: 0x90, B: 0xff, A: 0xff})
+	white := color.RGBAModel.Convert(color.White)
+	if c := color.RGBAModel.Convert(img.At(0, 0)); c != white {
+		t.Fatalf("got the margin of %v, want white", c)
+	}
+	// the top left module of the finder pattern is dark
+	_, scale, offset := layout(modules(t, o.Level), o)
+	if c := color.RGBAModel.Convert(img.At(offset+scale/2, offset+scale/2)); c != fg {
+		t.Fatalf("got the finder pattern of %v, want %v", c, fg)
+	}
+}
+
+func TestEncodeSVG(t *testing.T) {
+	o := validOptions()
+	o.Format = SVG
+	o.Size = 10
+	data, err := Encode(content, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := string(data)
+
+	// the image is larger than the size, a module takes a pixel at least
+	n := len(modules(t, o.Level)) + 2*o.Margin
+	for _, want := range []string{
+		fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d"`, n, n, n, n),
+		`<rect width="100%" height="100%" fill="#ffffff"/>`,
+		`<path fill="#000000" d="M4 4h1v1h-1z`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Fatalf("got %s, want %s in it", svg, want)
+		}
+	}
+	if !strings.HasSuffix(svg, "\"/></svg>\n") {
+		t.Fatalf("got an unclosed svg %s", svg)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	modules := make([][]bool, 21)
+	cases := []struct {
+		size, margin            int
+		wantSize, scale, offset int
+	}{
+		// 29 modules of 8 pixels, centered in 256
+		{256, 4, 256, 8, 12 + 32},
+		{29, 4, 29, 1, 4},
+		{10, 4, 29, 1, 4},
+		{21, 0, 21, 1, 0},
+	}
+	for _, c := range cases {
+		size, scale, offset := layout(modules, Options{Size: c.size, Margin: c.margin})
+		if size != c.wantSize || scale != c.scale || offset != c.offset {
+			t.Errorf("size %d, margin %d: got %d, %d, %d, want %d, %d, %d",
+				c.size, c.margin, size, scale, offset, c.wantSize, c.scale, c.offset)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	// sweepInterval is the interval of forgetting the full buckets
+	sweepInterval = time.Minute
+
+	// maxBuckets bounds the buckets of the clients, the idlest one is
+	// forgotten for a new client beyond it
+	maxBuckets = 1 << 16
+)
+
+// NewMemoryLimiter returns a Limiter of the buckets in process, it only limits
+// the requests served by this instance.
+func NewMemoryLimiter(rate Rate) Limiter {
+	return &memoryLimiter{
+		rate:       rate,
+		maxBuckets: maxBuckets,
+		buckets:    map[string]*list.Element{},
+		idle:       list.New(),
+		swept:      time.Now(),
+	}
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+type memoryLimiter struct {
+	rate       Rate
+	maxBuckets int
+
+	mtx     sync.Mutex
+	buckets map[string]*list.Element
+	// idle orders the buckets by their last request, the idlest at the back
+	idle  *list.List
+	swept time.Time
+}
+
+func (l *memoryLimiter) Allow(key string) (bool, time.Duration, error) {
+	ok, wait := l.allow(key, time.Now())
+	return ok, wait, nil
+}
+
+func (l *memoryLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		b = e.Value.(*bucket)
+		l.idle.MoveToFront(e)
+	} else {
+		if len(l.buckets) >= l.maxBuckets {
+			l.evict()
+		}
+		b = &bucket{key: key, tokens: float64(l.rate.Burst), last: now}
+		l.buckets[key] = l.idle.PushFront(b)
+	}
+	var wait time.Duration
+	b.tokens, wait = take(l.rate, b.tokens, b.last, now)
+	b.last = now
+	return wait == 0, wait
+}
+
+// sweep forgets the buckets which are full by now, they are the same as the
+// new ones. They are the idlest, so the walk stops at the first busy one.
+func (l *memoryLimiter) sweep(now time.Time) {
+	full := l.rate.fullIn()
+	for e := l.idle.Back(); e != nil; e = l.idle.Back() {
+		if now.Sub(e.Value.(*bucket).last) < full {
+			break
+		}
+		l.remove(e)
+	}
+	l.swept = now
+}
+
+// evict forgets the bucket which is idle for the longest.
+func (l *memoryLimiter) evict() {
+	if e := l.idle.Back(); e != nil {
+		l.remove(e)
+	}
+}
+
+func (l *memoryLimiter) remove(e *list.Element) {
+	l.idle.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
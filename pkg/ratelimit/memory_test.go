@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	rate := Rate{Limit: 2, Burst: 3}
+	now := time.Now()
+
+	cases := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		left    float64
+		wait    time.Duration
+	}{
+		{"full", 3, 0, 2, 0},
+		{"last token", 1, 0, 0, 0},
+		{"empty", 0, 0, 0, 500 * time.Millisecond},
+		{"half a token", 0.5, 0, 0.5, 250 * time.Millisecond},
+		{"refilled", 0, time.Second, 1, 0},
+		{"refilled up to burst", 0, time.Hour, 2, 0},
+		{"clock backwards", 0, -time.Second, 0, 500 * time.Millisecond},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			left, wait := take(rate, c.tokens, now.Add(-c.elapsed), now)
+			if left != c.left || wait != c.wait {
+				t.Fatalf("got %v tokens and %v, want %v and %v", left, wait, c.left, c.wait)
+			}
+		})
+	}
+
+	if full := rate.fullIn(); full != 1500*time.Millisecond {
+		t.Fatalf("got full in %v, want 1.5s", full)
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	l := NewMemoryLimiter(Rate{Limit: 1, Burst: 2}).(*memoryLimiter)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, wait := l.allow("a", now); !ok || wait != 0 {
+			t.Fatalf("request %d: got %v, %v within the burst", i, ok, wait)
+		}
+	}
+	if ok, wait := l.allow("a", now); ok || wait != time.Second {
+		t.Fatalf("got %v, %v, want a wait of 1s", ok, wait)
+	}
+	// the other clients have their own buckets
+	if ok, _ := l.allow("b", now); !ok {
+		t.Fatal("got the bucket of another client")
+	}
+	if ok, _ := l.allow("a", now.Add(time.Second)); !ok {
+		t.Fatal("got no refill after 1s")
+	}
+}
+
+func TestMemoryLimiterEvict(t *testing.T) {
+	l := NewMemoryLimiter(Rate{Limit: 1, Burst: 1}).(*memoryLimiter)
+	l.maxBuckets = 3
+	now := time.Now()
+
+	for i, key := range []string{"a", "b", "c", "a", "d"} {
+		l.allow(key, now.Add(time.Duration(i)*time.Millisecond))
+	}
+	// b is the idlest, a was taken again before d came
+	if _, ok := l.buckets["b"]; ok || len(l.buckets) != 3 || l.idle.Len() != 3 {
+		t.Fatalf("got buckets %v, want b evicted", keys(l))
+	}
+	if ok, _ := l.allow("a", now.Add(5*time.Millisecond)); ok {
+		t.Fatal("got the bucket of a evicted")
+	}
+	if ok, _ := l.allow("b", now.Add(5*time.Millisecond)); !ok {
+		t.Fatal("got the bucket of b kept")
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	l := NewMemoryLimiter(Rate{Limit: 1, Burst: 60}).(*memoryLimiter)
+	now := l.swept
+
+	l.allow("idle", now)
+	l.allow("busy", now.Add(30*time.Second))
+	// the sweep forgets the full bucket only
+	l.allow("new", now.Add(sweepInterval))
+	if got := keys(l); len(got) != 2 || got[0] != "new" || got[1] != "busy" {
+		t.Fatalf("got buckets %v, want new and busy", got)
+	}
+}
+
+// keys returns the keys of the buckets from the busiest.
+func keys(l *memoryLimiter) []string {
+	var keys []string
+	for e := l.idle.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*bucket).key)
+	}
+	return keys
+}
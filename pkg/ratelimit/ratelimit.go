@@ -0,0 +1,121 @@
+// Package ratelimit limits the requests of clients by token buckets. The
+// buckets are kept in redis, so the limits hold across the instances, or in
+// process if redis is not available.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+)
+
+const (
+	// backends of the buckets
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+// Rate is the rate of a bucket, it is refilled with Limit tokens per second up
+// to Burst tokens, and a request takes a token.
+type Rate struct {
+	Limit float64
+	Burst int
+}
+
+// Unlimited reports whether the rate limits nothing.
+func (r Rate) Unlimited() bool {
+	return r.Limit <= 0 || r.Burst <= 0
+}
+
+// Limiter limits the requests by the key of the client.
+type Limiter interface {
+	// Allow takes a token from the bucket of the key. If the bucket is empty,
+	// it reports how long to wait for the next token. The error of the backend
+	// is reported for logging only, ok and retryAfter hold even with it.
+	Allow(key string) (ok bool, retryAfter time.Duration, err error)
+}
+
+// Limits are the limiters of the endpoints, a nil limiter limits nothing.
+type Limits struct {
+	Create   Limiter
+	Redirect Limiter
+	Batch    Limiter
+}
+
+// New returns the Limits of the [ratelimit] config. The redis client may be nil
+// for the memory backend, the buckets fall back to the process if redis fails.
+func New(conf *config.Config, client *redis.Client) (Limits, error) {
+	var limits Limits
+	c := conf.RateLimit
+	if !c.Enabled {
+		return limits, nil
+	}
+
+	newLimiter := func(name string, rc config.Rate) (Limiter, error) {
+		rate := Rate{Limit: rc.Rate, Burst: rc.Burst}
+		if rate.Unlimited() {
+			return nil, nil
+		}
+		memory := NewMemoryLimiter(rate)
+		switch c.Backend {
+		case BackendRedis, "":
+			if client == nil {
+				return nil, fmt.Errorf("ratelimit: redis client is missing")
+			}
+			return NewRedisLimiter(client, name, rate, memory), nil
+		case BackendMemory:
+			return memory, nil
+		default:
+			return nil, fmt.Errorf("ratelimit: unknown backend %s", c.Backend)
+		}
+	}
+
+	var err error
+	if limits.Create, err = newLimiter("create", c.Create); err != nil {
+		return limits, err
+	}
+	if limits.Redirect, err = newLimiter("redirect", c.Redirect); err != nil {
+		return limits, err
+	}
+	if limits.Batch, err = newLimiter("batch", c.Batch); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+// take refills the bucket of tokens at last to now, and takes a token from it.
+// It returns the tokens left, and the wait for the next token if it is empty.
+func take(rate Rate, tokens float64, last time.Time, now time.Time) (float64, time.Duration) {
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(rate.Burst), tokens+elapsed*rate.Limit)
+	}
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+	return tokens, time.Duration(math.Ceil((1 - tokens) / rate.Limit * float64(time.Second)))
+}
+
+// fullIn returns how long an empty bucket takes to be full, the bucket may be
+// forgotten after it.
+func (r Rate) fullIn() time.Duration {
+	return time.Duration(math.Ceil(float64(r.Burst) / r.Limit * float64(time.Second)))
+}
+
+type clientKey struct{}
+
+// ContextWithClient returns a new Context that carries the address of the
+// client, the requests of anonymous callers are limited by it.
+func ContextWithClient(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, clientKey{}, addr)
+}
+
+// ClientFromContext returns the address of the client, empty if absent.
+func ClientFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(clientKey{}).(string)
+	return addr
+}
@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// cacheBucketKey is the key of the bucket of a client
+const cacheBucketKey = "surl:ratelimit:%s:%s"
+
+// takeScript takes a token from the bucket atomically, like take. It returns
+// the wait in milliseconds for the next token, 0 if the token is taken. The
+// bucket expires once it is full again.
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local b = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(b[1]) or burst
+local last = tonumber(b[2]) or now
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) / 1000 * limit)
+end
+
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) / limit * 1000)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens))
+redis.call("HSET", KEYS[1], "last", tostring(math.max(now, last)))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / limit * 1000))
+return wait
+`)
+
+// NewRedisLimiter returns a Limiter of the buckets in redis, which are shared
+// by the instances. The name tells the buckets of the limiters apart. If redis
+// fails, the requests are limited by the fallback instead.
+func NewRedisLimiter(client *redis.Client, name string, rate Rate, fallback Limiter) Limiter {
+	return &redisLimiter{
+		client:   client,
+		name:     name,
+		rate:     rate,
+		fallback: fallback,
+	}
+}
+
+type redisLimiter struct {
+	client   *redis.Client
+	name     string
+	rate     Rate
+	fallback Limiter
+}
+
+func (l *redisLimiter) Allow(key string) (bool, time.Duration, error) {
+	k := fmt.Sprintf(cacheBucketKey, l.name, key)
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	wait, err := takeScript.Run(l.client, []string{k}, l.rate.Limit, l.rate.Burst, now).Int64()
+	if err != nil {
+		if l.fallback != nil {
+			ok, retryAfter, _ := l.fallback.Allow(key)
+			return ok, retryAfter, err
+		}
+		return true, 0, err
+	}
+	return wait == 0, time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WiFeng/short-url/pkg/auth"
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+// maxKeyNameLength is the limit of the name of API keys
+const maxKeyNameLength = 128
+
+// APIKey is an API key. Key is the secret given to the caller, it is only
+// returned when the key is issued.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Key       string     `json:"key,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	Domain    string     `json:"domain,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func newAPIKey(k *dao.APIKey) *APIKey {
+	key := &APIKey{
+		ID:        k.ID,
+		Name:      k.Name,
+		Scopes:    k.Scopes,
+		Domain:    k.Domain,
+		CreatedAt: time.Unix(k.CreatedAt, 0).UTC(),
+		CreatedBy: k.CreatedBy,
+	}
+	if k.RevokedAt > 0 {
+		t := time.Unix(k.RevokedAt, 0).UTC()
+		key.RevokedAt = &t
+	}
+	return key
+}
+
+// IssueKey issues a key of the scopes. The key bound to a domain creates the
+// links of the domain only. The caller bound to a domain issues the keys of
+// it only, so it never mints a key beyond its domain.
+func (s *basicService) IssueKey(ctx context.Context, name string, scopes []string, domain string) (*APIKey, error) {
+	if err := checkLength("name", name, maxKeyNameLength); err != nil {
+		return nil, err
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: scopes are required", ErrInvalidRequest)
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidRequest, scope)
+		}
+	}
+
+	d, err := s.domain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	id, secret, hash, err := auth.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	k := &dao.APIKey{
+		ID:        id,
+		Hash:      hash,
+		Name:      name,
+		Scopes:    scopes,
+		Domain:    d.host,
+		CreatedAt: time.Now().Unix(),
+	}
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		k.CreatedBy = p.Subject
+	}
+	if err := s.dao.SetAPIKey(k); err != nil {
+		return nil, err
+	}
+
+	key := newAPIKey(k)
+	key.Key = secret
+	return key, nil
+}
+
+// RevokeKey revokes the key. The keys of the other domains are unknown to the
+// caller bound to a domain.
+func (s *basicService) RevokeKey(ctx context.Context, id string) (*APIKey, error) {
+	k, err := s.dao.GetAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		return nil, ErrNotFound
+	}
+	bound, err := s.bound(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if bound != nil && k.Domain != bound.host {
+		return nil, ErrNotFound
+	}
+	if k.RevokedAt > 0 {
+		return newAPIKey(k), nil
+	}
+
+	k.RevokedAt = time.Now().Unix()
+	if err := s.dao.SetAPIKey(k); err != nil {
+		return nil, err
+	}
+	return newAPIKey(k), nil
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/WiFeng/short-url/pkg/auth"
+)
+
+func TestKeyDomains(t *testing.T) {
+	conf := newTestConfig()
+	conf.General.Domains = []string{"https://a.example/", "https://b.example/"}
+	svc, _ := newTestService(t, conf)
+	ctx := context.Background()
+
+	unbound, err := svc.IssueKey(ctx, "unbound", []string{auth.ScopeWrite}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyA, err := svc.IssueKey(ctx, "a", []string{auth.ScopeAdmin}, "a.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyB, err := svc.IssueKey(ctx, "b", []string{auth.ScopeWrite}, "https://b.example/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyA.Domain != "a.example" || keyB.Domain != "b.example" || unbound.Domain != "" {
+		t.Fatalf("got domains %q, %q and %q", keyA.Domain, keyB.Domain, unbound.Domain)
+	}
+
+	// the admin bound to a.example
+	boundCtx := auth.ContextWithPrincipal(ctx, &auth.Principal{Subject: "key:" + keyA.ID, Scopes: keyA.Scopes, Domain: keyA.Domain})
+
+	for _, domain := range []string{"", "a.example", "https://a.example/"} {
+		k, err := svc.IssueKey(boundCtx, "a2", []string{auth.ScopeWrite}, domain)
+		if err != nil {
+			t.Fatalf("issue of %q: %v", domain, err)
+		}
+		if k.Domain != "a.example" {
+			t.Fatalf("issue of %q: got an unbound key of %q", domain, k.Domain)
+		}
+	}
+	if _, err := svc.IssueKey(boundCtx, "b2", []string{auth.ScopeWrite}, "b.example"); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("issue of b.example: got %v, want ErrForbidden", err)
+	}
+
+	for _, id := range []string{keyB.ID, unbound.ID} {
+		if _, err := svc.RevokeKey(boundCtx, id); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("revoke of %s: got %v, want ErrNotFound", id, err)
+		}
+	}
+	k, err := svc.RevokeKey(boundCtx, keyA.ID)
+	if err != nil || k.RevokedAt == nil {
+		t.Fatalf("revoke of the own domain: got %+v, %v", k, err)
+	}
+
+	// the unbound admin revokes any key
+	if k, err := svc.RevokeKey(ctx, keyB.ID); err != nil || k.RevokedAt == nil {
+		t.Fatalf("revoke of an unbound admin: got %+v, %v", k, err)
+	}
+}
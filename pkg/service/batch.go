@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+const (
+	// default of the max items of a batch
+	defaultMaxBatchSize = 1000
+)
+
+// BatchItem is an item of CreateBatch, the long URL and the options of it.
+type BatchItem struct {
+	LongURL string
+	CreateOptions
+}
+
+// BatchResult is the result of an item of CreateBatch, either the short URL or
+// the error of the item.
+type BatchResult struct {
+	ShortURL string
+	Err      error
+}
+
+// batchLink is a link of the batch to be generated.
+type batchLink struct {
+	index      int
+	domain     *shortDomain
+	link       *dao.Link
+	shortIDKey string
+	longIDKey  string
+
+	// same is the earlier link of the batch which is the same
+	same *batchLink
+}
+
+// CreateBatch creates the links of the items like Create, the results are in
+// the order of the items. The invalid items fail by themselves, the errors of
+// the storage fail the whole batch.
+//
+// The links without aliases are created together, the IDs are generated at a
+// time and the links are read and written by the batch methods of the storage.
+func (s *basicService) CreateBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error) {
+	maxSize := s.config.General.MaxBatchSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxBatchSize
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no items", ErrInvalidRequest)
+	}
+	if len(items) > maxSize {
+		return nil, fmt.Errorf("%w: more than %d items", ErrInvalidRequest, maxSize)
+	}
+
+	now := time.Now()
+	results := make([]BatchResult, len(items))
+	var pending []*batchLink
+	for i, item := range items {
+		shortDomain, err := s.domain(ctx, item.Domain)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		link, err := s.prepareLink(ctx, shortDomain, item.LongURL, item.CreateOptions, now)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		if item.Alias != "" {
+			shortURL, err := s.createAlias(shortDomain, link, item.Alias)
+			if err != nil && !isServiceError(err) {
+				return nil, err
+			}
+			results[i] = BatchResult{ShortURL: shortURL, Err: err}
+			continue
+		}
+
+		pending = append(pending, &batchLink{
+			index:      i,
+			domain:     shortDomain,
+			link:       link,
+			shortIDKey: shortDomain.shortIDKey(link.LongURL),
+		})
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	created, err := s.createLinks(pending, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range created {
+		results[b.index].ShortURL = s.domains.shortURL(b.longIDKey)
+	}
+	return results, nil
+}
+
+// createLinks reuses or generates the short codes of the links like Create,
+// and sets the longIDKey of them.
+func (s *basicService) createLinks(pending []*batchLink, now time.Time) ([]*batchLink, error) {
+	// the live links of the same long URLs
+	shortIDKeys := make([]string, len(pending))
+	for i, b := range pending {
+		shortIDKeys[i] = b.shortIDKey
+	}
+	indexed, err := s.dao.GetShortURLs(shortIDKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexedKeys []string
+	for _, idKey := range indexed {
+		if idKey != "" {
+			indexedKeys = append(indexedKeys, idKey)
+		}
+	}
+	olds, err := s.dao.GetLinks(indexedKeys)
+	if err != nil {
+		return nil, err
+	}
+	oldLinks := make(map[string]*dao.Link, len(indexedKeys))
+	for i, idKey := range indexedKeys {
+		oldLinks[idKey] = olds[i]
+	}
+
+	// the state of the index of each long URL, as the items are created in
+	// order
+	type index struct {
+		longIDKey string
+		link      *dao.Link
+		stale     bool
+
+		// from is the link of the batch, which is indexed once it is added
+		from *batchLink
+	}
+	indexes := make(map[string]*index, len(pending))
+	for i, b := range pending {
+		if _, ok := indexes[b.shortIDKey]; ok || indexed[i] == "" {
+			continue
+		}
+		old := oldLinks[indexed[i]]
+		indexes[b.shortIDKey] = &index{
+			longIDKey: indexed[i],
+			link:      old,
+			stale:     old == nil || old.LongURL != b.link.LongURL || !old.Live(now),
+		}
+	}
+
+	// the same links in the batch are created once
+	var toAdd []*batchLink
+	for _, b := range pending {
+		idx := indexes[b.shortIDKey]
+		if idx != nil && !idx.stale && sameLink(idx.link, b.link) {
+			if idx.from != nil {
+				b.same = idx.from
+			} else {
+				b.longIDKey = idx.longIDKey
+			}
+			continue
+		}
+		toAdd = append(toAdd, b)
+
+		// the index prefers the link never expires
+		if idx == nil || idx.stale || b.link.ExpiresAt == 0 {
+			indexes[b.shortIDKey] = &index{link: b.link, from: b}
+		}
+	}
+
+	if err := s.addLinks(toAdd); err != nil {
+		return nil, err
+	}
+
+	var setKeys, setVals []string
+	for shortIDKey, idx := range indexes {
+		if idx.from != nil {
+			setKeys = append(setKeys, shortIDKey)
+			setVals = append(setVals, idx.from.longIDKey)
+		}
+	}
+	if err := s.dao.SetShortURLs(setKeys, setVals); err != nil {
+		return nil, err
+	}
+
+	for _, b := range pending {
+		if b.same != nil {
+			b.longIDKey = b.same.longIDKey
+		}
+	}
+	return pending, nil
+}
+
+// addLinks adds the links with the generated IDs. The IDs taken by aliases or
+// reserved are skipped, the links of them are added one by one like Create.
+func (s *basicService) addLinks(links []*batchLink) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	ids, err := s.dao.GenerateIDs(len(links))
+	if err != nil {
+		return err
+	}
+	var toAdd []*batchLink
+	var idKeys []string
+	var daoLinks []*dao.Link
+	for i, b := range links {
+		code := s.convertToBase62Str(ids[i])
+		if s.isReserved(code) {
+			continue
+		}
+		toAdd = append(toAdd, b)
+		idKeys = append(idKeys, b.domain.key(code))
+		daoLinks = append(daoLinks, b.link)
+	}
+	added, err := s.dao.AddLinks(idKeys, daoLinks)
+	if err != nil {
+		return err
+	}
+	for i, b := range toAdd {
+		if added[i] {
+			b.longIDKey = idKeys[i]
+		}
+	}
+
+	for _, b := range links {
+		for b.longIDKey == "" {
+			nextID, err := s.dao.GenerateID()
+			if err != nil {
+				return err
+			}
+
+			code := s.convertToBase62Str(nextID)
+			if s.isReserved(code) {
+				continue
+			}
+			longIDKey := b.domain.key(code)
+			ok, err := s.dao.AddLink(longIDKey, b.link)
+			if err != nil {
+				return err
+			}
+			if ok {
+				b.longIDKey = longIDKey
+			}
+		}
+	}
+	return nil
+}
+
+// isServiceError reports whether the error is of the request, rather than of
+// the storage.
+func isServiceError(err error) bool {
+	var e *Error
+	return errors.As(err, &e)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreateBatch(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	old, err := svc.Create(ctx, "https://example.com/old", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the alias takes the code of the second ID of the batch
+	if _, err := svc.Create(ctx, "https://example.com/taken", CreateOptions{Alias: "2bK"}); err != nil {
+		t.Fatal(err)
+	}
+
+	items := []BatchItem{
+		{LongURL: "https://example.com/a"},
+		{LongURL: "ftp://example.com/"},
+		{LongURL: "https://example.com/b"},
+		{LongURL: "https://example.com/a"},
+		{LongURL: "https://example.com/old"},
+		{LongURL: "https://example.com/c", CreateOptions: CreateOptions{Alias: "sale"}},
+		{LongURL: "https://example.com/d", CreateOptions: CreateOptions{Alias: "sale"}},
+		{LongURL: "https://example.com/e", CreateOptions: CreateOptions{Alias: "admin"}},
+		{LongURL: "https://example.com/f", CreateOptions: CreateOptions{Alias: "2bI"}},
+	}
+	want := []BatchResult{
+		{ShortURL: "http://sh.url/2bJ"},
+		{Err: ErrInvalidURL},
+		// 2bK is taken by the alias, the link takes the next ID
+		{ShortURL: "http://sh.url/2bL"},
+		// the same link of the batch is created once
+		{ShortURL: "http://sh.url/2bJ"},
+		{ShortURL: old},
+		{ShortURL: "http://sh.url/sale"},
+		{Err: ErrAliasConflict},
+		{Err: ErrReservedAlias},
+		{Err: ErrAliasConflict},
+	}
+
+	results, err := svc.CreateBatch(ctx, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, r := range results {
+		if r.ShortURL != want[i].ShortURL || !errors.Is(r.Err, want[i].Err) {
+			t.Errorf("item %d: got %s, %v, want %s, %v", i, r.ShortURL, r.Err, want[i].ShortURL, want[i].Err)
+		}
+	}
+
+	// the links of the batch are the ones of Create
+	for _, i := range []int{0, 2} {
+		again, err := svc.Create(ctx, items[i].LongURL, CreateOptions{})
+		if err != nil || again != want[i].ShortURL {
+			t.Errorf("create of item %d: got %s, %v, want %s", i, again, err, want[i].ShortURL)
+		}
+	}
+	link, err := svc.Query(ctx, "2bK")
+	if err != nil || link.LongURL != "https://example.com/taken" {
+		t.Fatalf("got the alias overwritten: %+v, %v", link, err)
+	}
+}
+
+func TestCreateBatchSize(t *testing.T) {
+	conf := newTestConfig()
+	conf.General.MaxBatchSize = 2
+	svc, _ := newTestService(t, conf)
+	ctx := context.Background()
+
+	for _, n := range []int{0, 3} {
+		if _, err := svc.CreateBatch(ctx, make([]BatchItem, n)); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%d items: got %v, want ErrInvalidRequest", n, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+)
+
+// shortDomain is a short domain of the links. The codes of the domains other
+// than the default one are namespaced by their hosts in the storage, so each
+// domain has its own codes, and the existing codes of the default domain are
+// kept as they are.
+type shortDomain struct {
+	// prefix of the short URLs, e.g. "https://go.brand.com/"
+	prefix string
+
+	// host is the namespace of the codes, empty for the default domain
+	host string
+
+	// utm is the default utm parameters of the long URLs
+	utm UTM
+}
+
+// key returns the storage key of the code.
+func (d *shortDomain) key(code string) string {
+	if d.host == "" {
+		return code
+	}
+	return d.host + "/" + code
+}
+
+// shortIDKey returns the key of the index of the long URL, the same long URL
+// has a link in each domain.
+func (d *shortDomain) shortIDKey(longURL string) string {
+	if d.host == "" {
+		return fmt.Sprintf("%x", md5.Sum([]byte(longURL)))
+	}
+	return fmt.Sprintf("%x", md5.Sum([]byte(d.host+" "+longURL)))
+}
+
+// shortDomains are the short domains of the config by their hosts.
+type shortDomains struct {
+	def   *shortDomain
+	hosts map[string]*shortDomain
+
+	// path is the redirect prefix
+	path string
+}
+
+// newShortDomains returns the domains, the prefixes of their short URLs end
+// with the redirect prefix. The utm defaults are by the hosts of the domains,
+// "*" is of the domains without their own.
+func newShortDomains(def string, others []string, redirectPrefix string, utm map[string]config.UTM) *shortDomains {
+	d := &shortDomains{
+		def:   &shortDomain{prefix: joinPrefix(def, redirectPrefix)},
+		hosts: map[string]*shortDomain{},
+		path:  redirectPrefix,
+	}
+	if host := domainHost(def); host != "" {
+		d.hosts[host] = d.def
+	}
+	for _, prefix := range others {
+		host := domainHost(prefix)
+		if _, ok := d.hosts[host]; ok || host == "" {
+			continue
+		}
+		d.hosts[host] = &shortDomain{prefix: joinPrefix(prefix, redirectPrefix), host: host}
+	}
+
+	d.def.utm = UTM(utm["*"])
+	for host, domain := range d.hosts {
+		defaults, ok := utm[host]
+		if !ok {
+			defaults = utm["*"]
+		}
+		domain.utm = UTM(defaults)
+	}
+	return d
+}
+
+// lookup returns the domain of the name, which is the host or the prefix of
+// the domain. The empty name is the default domain.
+func (d *shortDomains) lookup(name string) (*shortDomain, error) {
+	if name == "" {
+		return d.def, nil
+	}
+	if domain, ok := d.hosts[domainHost(name)]; ok {
+		return domain, nil
+	}
+	return nil, fmt.Errorf("%w: unknown domain %s", ErrInvalidRequest, name)
+}
+
+// idKey returns the storage key of the short URL, which is the code, the host
+// and the code, or the whole short URL. The codes of the unknown hosts are of
+// the default domain, e.g. the ones redirected by the address of the server.
+func (d *shortDomains) idKey(shortURL string) string {
+	i := strings.LastIndex(shortURL, "/")
+	if i < 0 {
+		return shortURL
+	}
+	code := shortURL[i+1:]
+	if domain, ok := d.hosts[domainHost(shortURL[:i])]; ok {
+		return domain.key(code)
+	}
+	return code
+}
+
+// of returns the domain and the code of the storage key.
+func (d *shortDomains) of(idKey string) (*shortDomain, string) {
+	i := strings.Index(idKey, "/")
+	if i < 0 {
+		return d.def, idKey
+	}
+	host, code := idKey[:i], idKey[i+1:]
+	if domain, ok := d.hosts[host]; ok {
+		return domain, code
+	}
+	// the domain is removed from the config
+	return &shortDomain{prefix: joinPrefix("http://"+host+"/", d.path), host: host}, code
+}
+
+// shortURL returns the short URL of the storage key.
+func (d *shortDomains) shortURL(idKey string) string {
+	domain, code := d.of(idKey)
+	return domain.prefix + code
+}
+
+// joinPrefix returns the prefix of the short URLs of the domain, which are
+// redirected at the path.
+func joinPrefix(domain string, path string) string {
+	if !strings.HasSuffix(domain, "/") {
+		domain += "/"
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return domain
+	}
+	return domain + path + "/"
+}
+
+// domainHost returns the lowercased host without the port of the prefix or
+// the host of a domain.
+func domainHost(s string) string {
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+)
+
+// Error codes are stable and machine-readable, API clients may branch on them.
+const (
+	CodeInternal       = "internal"
+	CodeInvalidRequest = "invalid_request"
+	CodeInvalidURL     = "invalid_url"
+	CodeInvalidAlias   = "invalid_alias"
+	CodeInvalidExpiry  = "invalid_expiry"
+	CodeBlockedURL     = "blocked_url"
+	CodeNotFound       = "not_found"
+	CodeAliasConflict  = "alias_conflict"
+	CodeExpired        = "expired"
+	CodeDisabled       = "disabled"
+	CodeDeleted        = "deleted"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeRateLimited    = "rate_limited"
+)
+
+var (
+	// ErrInvalidRequest is returned when the request is malformed.
+	ErrInvalidRequest = NewError(CodeInvalidRequest, "invalid request")
+
+	// ErrInvalidURL is returned when the long URL is not acceptable.
+	ErrInvalidURL = NewError(CodeInvalidURL, "invalid long url")
+
+	// ErrInvalidAlias is returned when the alias has disallowed characters or
+	// length.
+	ErrInvalidAlias = NewError(CodeInvalidAlias, "alias must be 3 to 32 characters of [0-9A-Za-z_-]")
+
+	// ErrReservedAlias is returned when the alias is a reserved word, which
+	// may shadow the other paths.
+	ErrReservedAlias = NewError(CodeInvalidAlias, "alias is reserved")
+
+	// ErrInvalidExpiry is returned when the expiry is in the past, or both of
+	// expires_in and expires_at are given.
+	ErrInvalidExpiry = NewError(CodeInvalidExpiry, "invalid expiry")
+
+	// ErrBlockedURL is returned when the long URL goes to a blocked
+	// destination.
+	ErrBlockedURL = NewError(CodeBlockedURL, "long url is blocked")
+
+	// ErrNotFound is returned when the short code is unknown.
+	ErrNotFound = NewError(CodeNotFound, "link not found")
+
+	// ErrAliasConflict is returned when the alias is taken by another long URL.
+	ErrAliasConflict = NewError(CodeAliasConflict, "alias is already taken")
+
+	// ErrExpired is returned when the link is expired.
+	ErrExpired = NewError(CodeExpired, "link is expired")
+
+	// ErrDisabled is returned when the link is disabled.
+	ErrDisabled = NewError(CodeDisabled, "link is disabled")
+
+	// ErrDeleted is returned when the link is deleted.
+	ErrDeleted = NewError(CodeDeleted, "link is deleted")
+
+	// ErrUnauthorized is returned when the caller is not authenticated.
+	ErrUnauthorized = NewError(CodeUnauthorized, "unauthorized")
+
+	// ErrForbidden is returned when the caller is not allowed to do it.
+	ErrForbidden = NewError(CodeForbidden, "forbidden")
+
+	// ErrRateLimited is returned when the caller sends too many requests.
+	ErrRateLimited = NewError(CodeRateLimited, "rate limited")
+)
+
+// Error is the error of the service with a stable code. The errors above are
+// compared by errors.Is, so they may be wrapped with more details.
+type Error struct {
+	Code    string
+	Message string
+}
+
+// NewError returns an Error.
+func NewError(code string, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether the target is an Error of the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// ErrorCode returns the code of the error, CodeInternal if it is not an Error.
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
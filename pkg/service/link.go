@@ -0,0 +1,216 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+// limits of the metadata of links
+const (
+	maxTitleLength    = 255
+	maxTagLength      = 50
+	maxTags           = 16
+	maxNotesLength    = 4096
+	maxCampaignLength = 128
+	maxCreatorLength  = 128
+)
+
+// RedirectInterstitial is the type of the links redirected by a page rather
+// than a status code, the other types are 301, 302, 307 and 308.
+const RedirectInterstitial = "interstitial"
+
+// redirectTypes are the valid types of the redirects of links.
+var redirectTypes = map[string]bool{
+	"301":                true,
+	"302":                true,
+	"307":                true,
+	"308":                true,
+	RedirectInterstitial: true,
+}
+
+// Link is the record of a short link.
+type Link struct {
+	ShortURL  string     `json:"short_url"`
+	LongURL   string     `json:"long_url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	Title     string     `json:"title,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	Campaign  string     `json:"campaign,omitempty"`
+	Disabled  bool       `json:"disabled,omitempty"`
+
+	// UTM is the utm parameters of the long URL.
+	UTM *UTM `json:"utm,omitempty"`
+
+	// Redirect is the type of the redirect, the one of the config if empty.
+	Redirect string `json:"redirect,omitempty"`
+
+	// Blocked is set if the destination is blocked by the filter, the link
+	// is not redirected.
+	Blocked bool `json:"blocked,omitempty"`
+
+	// Key is the code namespaced by the short domain, the clicks are counted
+	// by it.
+	Key string `json:"-"`
+}
+
+// Metadata is the descriptive fields of a link, they don't change where the
+// link goes.
+type Metadata struct {
+	CreatedBy string
+	Title     string
+	Tags      []string
+	Notes     string
+	Campaign  string
+}
+
+// UpdateOptions collects the fields to update, the nil ones are unchanged.
+// LongURL retargets the link and keeps its short code, Disabled stops or
+// resumes redirecting, Redirect changes the type of the redirect.
+type UpdateOptions struct {
+	LongURL  *string
+	Disabled *bool
+	Redirect *string
+
+	Title    *string
+	Tags     *[]string
+	Notes    *string
+	Campaign *string
+}
+
+func newLink(shortURL string, l *dao.Link) *Link {
+	link := &Link{
+		ShortURL:  shortURL,
+		LongURL:   l.LongURL,
+		CreatedBy: l.CreatedBy,
+		Title:     l.Title,
+		Tags:      l.Tags,
+		Notes:     l.Notes,
+		Campaign:  l.Campaign,
+		Disabled:  l.Disabled,
+		Redirect:  l.Redirect,
+	}
+	if l.UTM != nil {
+		utm := UTM(*l.UTM)
+		link.UTM = &utm
+	}
+	if l.ExpiresAt > 0 {
+		t := time.Unix(l.ExpiresAt, 0).UTC()
+		link.ExpiresAt = &t
+	}
+	if l.CreatedAt > 0 {
+		t := time.Unix(l.CreatedAt, 0).UTC()
+		link.CreatedAt = &t
+	}
+	return link
+}
+
+// sameLink reports whether the links are the same except the creation time,
+// so one of them can be reused for the other.
+func sameLink(a *dao.Link, b *dao.Link) bool {
+	if a.LongURL != b.LongURL || a.ExpiresAt != b.ExpiresAt || a.CreatedBy != b.CreatedBy ||
+		a.Title != b.Title || a.Notes != b.Notes || a.Campaign != b.Campaign || a.Disabled != b.Disabled ||
+		a.Redirect != b.Redirect || a.DeletedAt != b.DeletedAt || len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// setMetadata validates the metadata and sets it to the link.
+func setMetadata(link *dao.Link, meta Metadata) error {
+	if err := checkLength("created_by", meta.CreatedBy, maxCreatorLength); err != nil {
+		return err
+	}
+	link.CreatedBy = meta.CreatedBy
+	return updateMetadata(link, UpdateOptions{
+		Title:    &meta.Title,
+		Tags:     &meta.Tags,
+		Notes:    &meta.Notes,
+		Campaign: &meta.Campaign,
+	})
+}
+
+// updateMetadata validates the given fields and sets them to the link.
+func updateMetadata(link *dao.Link, opts UpdateOptions) error {
+	if opts.Title != nil {
+		if err := checkLength("title", *opts.Title, maxTitleLength); err != nil {
+			return err
+		}
+		link.Title = *opts.Title
+	}
+	if opts.Notes != nil {
+		if err := checkLength("notes", *opts.Notes, maxNotesLength); err != nil {
+			return err
+		}
+		link.Notes = *opts.Notes
+	}
+	if opts.Campaign != nil {
+		if err := checkLength("campaign", *opts.Campaign, maxCampaignLength); err != nil {
+			return err
+		}
+		link.Campaign = *opts.Campaign
+	}
+	if opts.Tags != nil {
+		tags, err := normalizeTags(*opts.Tags)
+		if err != nil {
+			return err
+		}
+		link.Tags = tags
+	}
+	return nil
+}
+
+// normalizeTags trims the tags and removes the duplicates, keeping the order.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool, len(tags))
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("%w: empty tag", ErrInvalidRequest)
+		}
+		if err := checkLength("tag", tag, maxTagLength); err != nil {
+			return nil, err
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		res = append(res, tag)
+	}
+
+	if len(res) > maxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidRequest, maxTags)
+	}
+	return res, nil
+}
+
+// checkRedirect validates the type of the redirect, empty is the default.
+func checkRedirect(redirect string) error {
+	if redirect != "" && !redirectTypes[redirect] {
+		return fmt.Errorf("%w: unknown redirect %s", ErrInvalidRequest, redirect)
+	}
+	return nil
+}
+
+func checkLength(name string, value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%w: %s is longer than %d characters", ErrInvalidRequest, name, max)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	shortURL, err := svc.Create(ctx, "https://example.com/old", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	longURL := "https://Example.com:443/new"
+	title := "New"
+	tags := []string{" go ", "news", "go"}
+	link, err := svc.Update(ctx, shortURL, UpdateOptions{LongURL: &longURL, Title: &title, Tags: &tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.LongURL != "https://example.com/new" || link.Title != title || !reflect.DeepEqual(link.Tags, []string{"go", "news"}) {
+		t.Fatalf("got %+v", link)
+	}
+
+	// the new long URL is indexed to the link, and the old one is not
+	if again, err := svc.Create(ctx, "https://example.com/new", CreateOptions{Metadata: Metadata{Title: title, Tags: []string{"go", "news"}}}); err != nil || again != shortURL {
+		t.Fatalf("create of the new long URL: got %s, %v, want %s", again, err, shortURL)
+	}
+	if old, err := svc.Create(ctx, "https://example.com/old", CreateOptions{}); err != nil || old == shortURL {
+		t.Fatalf("create of the old long URL: got %s, %v", old, err)
+	}
+
+	disabled := true
+	if _, err := svc.Update(ctx, shortURL, UpdateOptions{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Query(ctx, shortURL); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("got %v, want ErrDisabled", err)
+	}
+	disabled = false
+	if _, err := svc.Update(ctx, shortURL, UpdateOptions{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Query(ctx, shortURL); err != nil {
+		t.Fatalf("got %v of the enabled link", err)
+	}
+}
+
+func TestUpdateInvalid(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	shortURL, err := svc.Create(ctx, "https://example.com/", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str := func(s string) *string { return &s }
+	cases := []struct {
+		name string
+		opts UpdateOptions
+		err  error
+	}{
+		{"long url", UpdateOptions{LongURL: str("javascript:alert(1)")}, ErrInvalidURL},
+		{"redirect", UpdateOptions{Redirect: str("303")}, ErrInvalidRequest},
+		{"title", UpdateOptions{Title: str(strings.Repeat("t", maxTitleLength+1))}, ErrInvalidRequest},
+		{"empty tag", UpdateOptions{Tags: &[]string{"go", " "}}, ErrInvalidRequest},
+	}
+	for _, c := range cases {
+		if _, err := svc.Update(ctx, shortURL, c.opts); !errors.Is(err, c.err) {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+		}
+	}
+	if link, err := svc.Query(ctx, shortURL); err != nil || link.LongURL != "https://example.com/" || link.Redirect != "" {
+		t.Fatalf("got %+v, %v, want the link unchanged", link, err)
+	}
+
+	if _, err := svc.Update(ctx, "nope", UpdateOptions{Title: str("a")}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if err := svc.Delete(ctx, shortURL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Update(ctx, shortURL, UpdateOptions{Title: str("a")}); !errors.Is(err, ErrDeleted) {
+		t.Fatalf("got %v, want ErrDeleted", err)
+	}
+}
+
+func TestRedirectTypes(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	for _, redirect := range []string{"", "301", "302", "307", "308", RedirectInterstitial} {
+		shortURL, err := svc.Create(ctx, "https://example.com/"+redirect, CreateOptions{Redirect: redirect})
+		if err != nil {
+			t.Fatalf("redirect %q: %v", redirect, err)
+		}
+		link, err := svc.Query(ctx, shortURL)
+		if err != nil || link.Redirect != redirect {
+			t.Fatalf("redirect %q: got %+v, %v", redirect, link, err)
+		}
+	}
+	if _, err := svc.Create(ctx, "https://example.com/", CreateOptions{Redirect: "200"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("got %v, want ErrInvalidRequest", err)
+	}
+}
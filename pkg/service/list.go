@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+const (
+	// sort of listing links
+	SortCreatedAt = dao.SortCreatedAt
+	SortClicks    = dao.SortClicks
+
+	// order of listing links
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// ListOptions collects the filters, sort and page of List. The zero values
+// of the filters match all.
+type ListOptions struct {
+	Tag       string
+	CreatedBy string
+
+	// CreatedAfter is inclusive and CreatedBefore is exclusive.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// LongURL is a substring of the long URL, and Domain matches the host of
+	// the long URL and its subdomains.
+	LongURL string
+	Domain  string
+
+	// Sort is SortCreatedAt by default, and Order is OrderDesc by default.
+	Sort  string
+	Order string
+
+	// Cursor is the NextCursor of the previous page, empty for the first
+	// page. It is only valid with the same sort and order.
+	Cursor string
+	Limit  int
+}
+
+// LinkList is a page of links.
+type LinkList struct {
+	Links      []*ListItem `json:"links"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// ListItem is a listed link with its clicks.
+type ListItem struct {
+	*Link
+	Clicks int64 `json:"clicks"`
+}
+
+func (s *basicService) listOptions(opts ListOptions) (dao.ListOptions, error) {
+	o := dao.ListOptions{
+		Tag:       strings.TrimSpace(opts.Tag),
+		CreatedBy: opts.CreatedBy,
+		LongURL:   opts.LongURL,
+		Domain:    strings.ToLower(strings.TrimSpace(opts.Domain)),
+		Limit:     opts.Limit,
+	}
+	if !opts.CreatedAfter.IsZero() {
+		o.CreatedAfter = opts.CreatedAfter.Unix()
+	}
+	if !opts.CreatedBefore.IsZero() {
+		o.CreatedBefore = opts.CreatedBefore.Unix()
+	}
+
+	switch opts.Sort {
+	case "", SortCreatedAt:
+		o.Sort = SortCreatedAt
+	case SortClicks:
+		o.Sort = SortClicks
+	default:
+		return o, fmt.Errorf("%w: unknown sort %q", ErrInvalidRequest, opts.Sort)
+	}
+
+	switch opts.Order {
+	case "", OrderDesc:
+		o.Desc = true
+	case OrderAsc:
+	default:
+		return o, fmt.Errorf("%w: unknown order %q", ErrInvalidRequest, opts.Order)
+	}
+
+	switch {
+	case o.Limit < 0 || o.Limit > maxListLimit:
+		return o, fmt.Errorf("%w: limit must be 1 to %d", ErrInvalidRequest, maxListLimit)
+	case o.Limit == 0:
+		o.Limit = defaultListLimit
+	}
+
+	if opts.Cursor != "" {
+		cursor, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return o, fmt.Errorf("%w: invalid cursor", ErrInvalidRequest)
+		}
+		o.After = cursor
+	}
+	return o, nil
+}
+
+// encodeCursor makes the cursor opaque to the clients.
+func encodeCursor(c *dao.Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*dao.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := &dao.Cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
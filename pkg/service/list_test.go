@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestList(t *testing.T) {
+	svc, store := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	var shortURLs []string
+	for i, tag := range []string{"go", "rust", "go"} {
+		shortURL, err := svc.Create(ctx, "https://example.com/"+string(rune('a'+i)), CreateOptions{Metadata: Metadata{Tags: []string{tag}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		shortURLs = append(shortURLs, shortURL)
+	}
+	// the links are created in the same second, so the codes break the ties
+	for i, n := range []int64{5, 1, 3} {
+		if err := store.IncrClicks(shortURLs[i][len("http://sh.url/"):], n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the pages of 2 of the newest first
+	first, err := svc.List(ctx, ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := listed(first); len(got) != 2 || got[0] != shortURLs[2] || got[1] != shortURLs[1] || first.NextCursor == "" {
+		t.Fatalf("got the first page %v, %q", got, first.NextCursor)
+	}
+	second, err := svc.List(ctx, ListOptions{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := listed(second); len(got) != 1 || got[0] != shortURLs[0] || second.NextCursor != "" {
+		t.Fatalf("got the second page %v, %q", got, second.NextCursor)
+	}
+
+	cases := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"by clicks", ListOptions{Sort: SortClicks}, []string{shortURLs[0], shortURLs[2], shortURLs[1]}},
+		{"by clicks asc", ListOptions{Sort: SortClicks, Order: OrderAsc}, []string{shortURLs[1], shortURLs[2], shortURLs[0]}},
+		{"tag", ListOptions{Tag: "go", Order: OrderAsc}, []string{shortURLs[0], shortURLs[2]}},
+		{"long url", ListOptions{LongURL: "example.com/b"}, []string{shortURLs[1]}},
+		{"domain", ListOptions{Domain: "other.com"}, nil},
+		{"created before", ListOptions{CreatedBefore: time.Now().Add(-time.Hour)}, nil},
+	}
+	for _, c := range cases {
+		list, err := svc.List(ctx, c.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := listed(list); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// the deleted links are not listed
+	if err := svc.Delete(ctx, shortURLs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := svc.List(ctx, ListOptions{Tag: "go"}); err != nil || len(list.Links) != 1 {
+		t.Fatalf("got %v, %v, want the live link only", listed(list), err)
+	}
+}
+
+func TestListInvalid(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	for _, opts := range []ListOptions{
+		{Sort: "title"},
+		{Order: "up"},
+		{Limit: -1},
+		{Limit: maxListLimit + 1},
+		{Cursor: "not a cursor"},
+	} {
+		if _, err := svc.List(ctx, opts); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%+v: got %v, want ErrInvalidRequest", opts, err)
+		}
+	}
+}
+
+func listed(list *LinkList) []string {
+	if list == nil {
+		return nil
+	}
+	var shortURLs []string
+	for _, item := range list.Links {
+		shortURLs = append(shortURLs, item.ShortURL)
+	}
+	return shortURLs
+}
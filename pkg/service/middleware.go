@@ -22,17 +22,95 @@ type loggingMiddleware struct {
 	next   Service
 }
 
-func (mw loggingMiddleware) Create(ctx context.Context, longURL string) (shortURL string, err error) {
+func (mw loggingMiddleware) Create(ctx context.Context, longURL string, opts CreateOptions) (shortURL string, err error) {
 	defer func() {
-		mw.logger.Infow("defer caller", "method", "Create", "longURL", longURL, "shortURL", shortURL, "err", err)
+		mw.logger.Infow("defer caller", "method", "Create", "longURL", longURL, "opts", opts, "shortURL", shortURL, "err", err)
 	}()
-	return mw.next.Create(ctx, longURL)
+	return mw.next.Create(ctx, longURL, opts)
 }
 
-func (mw loggingMiddleware) Query(ctx context.Context, shortURL string) (longURL string, err error) {
+func (mw loggingMiddleware) CreateBatch(ctx context.Context, items []BatchItem) (results []BatchResult, err error) {
 	defer func() {
+		log.Infow(ctx, "defer caller", "method", "CreateBatch", "items", len(items), "err", err)
+	}()
+	return mw.next.CreateBatch(ctx, items)
+}
+
+func (mw loggingMiddleware) Query(ctx context.Context, shortURL string) (link *Link, err error) {
+	defer func() {
+		var longURL string
+		if link != nil {
+			longURL = link.LongURL
+		}
 		// mw.logger.Infow("defer caller", "method", "Query", "shortURL", shortURL, "longURL", longURL, "err", err)
 		log.Infow(ctx, "defer caller", "method", "Query", "shortURL", shortURL, "longURL", longURL, "err", err)
 	}()
 	return mw.next.Query(ctx, shortURL)
 }
+
+func (mw loggingMiddleware) Update(ctx context.Context, shortURL string, opts UpdateOptions) (link *Link, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "Update", "shortURL", shortURL, "err", err)
+	}()
+	return mw.next.Update(ctx, shortURL, opts)
+}
+
+func (mw loggingMiddleware) Delete(ctx context.Context, shortURL string) (err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "Delete", "shortURL", shortURL, "err", err)
+	}()
+	return mw.next.Delete(ctx, shortURL)
+}
+
+func (mw loggingMiddleware) List(ctx context.Context, opts ListOptions) (list *LinkList, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "List", "opts", opts, "err", err)
+	}()
+	return mw.next.List(ctx, opts)
+}
+
+func (mw loggingMiddleware) IssueKey(ctx context.Context, name string, scopes []string, domain string) (key *APIKey, err error) {
+	defer func() {
+		var id string
+		if key != nil {
+			id = key.ID
+		}
+		log.Infow(ctx, "defer caller", "method", "IssueKey", "name", name, "scopes", scopes, "domain", domain, "id", id, "err", err)
+	}()
+	return mw.next.IssueKey(ctx, name, scopes, domain)
+}
+
+func (mw loggingMiddleware) RevokeKey(ctx context.Context, id string) (key *APIKey, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "RevokeKey", "id", id, "err", err)
+	}()
+	return mw.next.RevokeKey(ctx, id)
+}
+
+func (mw loggingMiddleware) Clicks(ctx context.Context, shortURL string) (clicks int64, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "Clicks", "shortURL", shortURL, "clicks", clicks, "err", err)
+	}()
+	return mw.next.Clicks(ctx, shortURL)
+}
+
+func (mw loggingMiddleware) Stats(ctx context.Context, shortURL string) (stats *Stats, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "Stats", "shortURL", shortURL, "err", err)
+	}()
+	return mw.next.Stats(ctx, shortURL)
+}
+
+func (mw loggingMiddleware) GroupStats(ctx context.Context, opts GroupOptions) (groups []*Group, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "GroupStats", "opts", opts, "err", err)
+	}()
+	return mw.next.GroupStats(ctx, opts)
+}
+
+func (mw loggingMiddleware) QRCode(ctx context.Context, shortURL string, opts QROptions) (code *QRCode, err error) {
+	defer func() {
+		log.Infow(ctx, "defer caller", "method", "QRCode", "shortURL", shortURL, "format", opts.Format, "err", err)
+	}()
+	return mw.next.QRCode(ctx, shortURL, opts)
+}
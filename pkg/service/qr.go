@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/WiFeng/short-url/pkg/qr"
+)
+
+// defaults of the qr codes, unless they are configured
+const (
+	defaultQRSize       = 256
+	defaultQRMaxSize    = 2048
+	defaultQRLevel      = "M"
+	defaultQRMargin     = 4
+	defaultQRForeground = "#000000"
+	defaultQRBackground = "#ffffff"
+
+	// the margin is in modules, so it is capped rather than the pixels
+	maxQRMargin = 32
+)
+
+// QROptions collects the optional parameters of QRCode, the empty ones are
+// the defaults of the config.
+type QROptions struct {
+	// Format is png or svg, png if empty.
+	Format string
+
+	// Size is the width and the height in pixels, up to the max size of the
+	// config.
+	Size int
+
+	// Level is the error correction level, one of L, M, Q and H.
+	Level string
+
+	// Margin is the quiet zone in modules, 0 is no margin.
+	Margin *int
+
+	// Foreground and Background are the colors in #rgb or #rrggbb.
+	Foreground string
+	Background string
+}
+
+// QRCode is the image of the QR code of a short URL.
+type QRCode struct {
+	ContentType string
+	Data        []byte
+}
+
+// QRCode renders the canonical short URL of the live link as a QR code. The
+// links to blocked destinations have no QR codes, so they are not spread.
+func (s *basicService) QRCode(ctx context.Context, shortURL string, opts QROptions) (*QRCode, error) {
+	o, err := s.qrOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := s.Query(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if link.Blocked {
+		return nil, ErrBlockedURL
+	}
+
+	data, err := qr.Encode(link.ShortURL, o)
+	if err != nil {
+		return nil, err
+	}
+	return &QRCode{ContentType: qr.ContentTypes[o.Format], Data: data}, nil
+}
+
+// qrOptions returns the options of the image, the empty ones of the request
+// are the ones of the config or the defaults.
+func (s *basicService) qrOptions(opts QROptions) (qr.Options, error) {
+	conf := s.config.QR
+	o := qr.Options{
+		Format:     strings.ToLower(firstNonEmpty(opts.Format, qr.PNG)),
+		Size:       defaultQRSize,
+		Level:      strings.ToUpper(firstNonEmpty(opts.Level, conf.Level, defaultQRLevel)),
+		Margin:     defaultQRMargin,
+		Foreground: firstNonEmpty(opts.Foreground, conf.Foreground, defaultQRForeground),
+		Background: firstNonEmpty(opts.Background, conf.Background, defaultQRBackground),
+	}
+	if conf.Size > 0 {
+		o.Size = conf.Size
+	}
+	if opts.Size != 0 {
+		o.Size = opts.Size
+	}
+	if conf.Margin != nil {
+		o.Margin = *conf.Margin
+	}
+	if opts.Margin != nil {
+		o.Margin = *opts.Margin
+	}
+
+	maxSize := defaultQRMaxSize
+	if conf.MaxSize > 0 {
+		maxSize = conf.MaxSize
+	}
+	if o.Size > maxSize {
+		return o, fmt.Errorf("%w: size is larger than %d", ErrInvalidRequest, maxSize)
+	}
+	if o.Margin > maxQRMargin {
+		return o, fmt.Errorf("%w: margin is larger than %d", ErrInvalidRequest, maxQRMargin)
+	}
+	if err := o.Check(); err != nil {
+		return o, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return o, nil
+}
+
+// firstNonEmpty returns the first of the values which is not empty.
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
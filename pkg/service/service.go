@@ -2,14 +2,24 @@ package service
 
 import (
 	"context"
-	"crypto/md5"
-	"database/sql"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
-	"github.com/go-redis/redis"
+	"github.com/WiFeng/short-url/pkg/auth"
 	"github.com/WiFeng/short-url/pkg/core/config"
 	"github.com/WiFeng/short-url/pkg/core/log"
 	"github.com/WiFeng/short-url/pkg/dao"
+	"github.com/WiFeng/short-url/pkg/filter"
+)
+
+var (
+	aliasRegexp = regexp.MustCompile(`^[0-9A-Za-z_-]{3,32}$`)
+
+	// reservedWords are the paths of the other routes, which may be shadowed
+	// by the codes if the redirects are at the root.
+	reservedWords = []string{"admin", "debug", "healthz", "metrics", "x"}
 )
 
 var (
@@ -27,36 +37,110 @@ var (
 	}
 )
 
-// Service describes a service that adds things together.
+// Service describes the short URL service: it creates the short links of long
+// URLs in the short domains, resolves, updates and deletes them, reports
+// their clicks and stats, renders their QR codes, and manages the API keys.
 type Service interface {
-	Create(ctx context.Context, longURL string) (string, error)
-	Query(ctx context.Context, shortURL string) (string, error)
+	Create(ctx context.Context, longURL string, opts CreateOptions) (string, error)
+	CreateBatch(ctx context.Context, items []BatchItem) ([]BatchResult, error)
+	Query(ctx context.Context, shortURL string) (*Link, error)
+	Update(ctx context.Context, shortURL string, opts UpdateOptions) (*Link, error)
+	Delete(ctx context.Context, shortURL string) error
+	List(ctx context.Context, opts ListOptions) (*LinkList, error)
+	IssueKey(ctx context.Context, name string, scopes []string, domain string) (*APIKey, error)
+	RevokeKey(ctx context.Context, id string) (*APIKey, error)
+	Clicks(ctx context.Context, shortURL string) (int64, error)
+	Stats(ctx context.Context, shortURL string) (*Stats, error)
+	GroupStats(ctx context.Context, opts GroupOptions) ([]*Group, error)
+	QRCode(ctx context.Context, shortURL string, opts QROptions) (*QRCode, error)
+}
+
+// CreateOptions collects the optional parameters of Create.
+type CreateOptions struct {
+	// Alias is the custom short code, it is generated if empty.
+	Alias string
+
+	// Domain is the host or the prefix of the short domain, the one of the
+	// caller or the default one if empty.
+	Domain string
+
+	// ExpiresIn and ExpiresAt are exclusive, the link never expires if both
+	// are zero.
+	ExpiresIn time.Duration
+	ExpiresAt time.Time
+
+	// Redirect is the type of the redirect, one of 301, 302, 307, 308 and
+	// interstitial. The one of the config if empty.
+	Redirect string
+
+	// UTM is merged onto the long URL, the empty parameters are the ones of
+	// the long URL or the defaults of the domain.
+	UTM UTM
+
+	Metadata
 }
 
 // New returns a basic Service with all of the expected middlewares wired in.
-func New(conf *config.Config, db *sql.DB, re *redis.Client, logger log.Logger) Service {
+// The long URLs are checked by the filter, if it is not nil.
+func New(conf *config.Config, store dao.Storage, urlFilter *filter.Filter, logger log.Logger) Service {
 	var svc Service
 	{
-		svc = NewBasicService(conf, db, re, logger)
+		svc = NewBasicService(conf, store, urlFilter, logger)
 		svc = LoggingMiddleware(logger)(svc)
 	}
 	return svc
 }
 
 // NewBasicService returns a native, stateless implementation of Service.
-func NewBasicService(conf *config.Config, db *sql.DB, re *redis.Client, logger log.Logger) Service {
+func NewBasicService(conf *config.Config, store dao.Storage, urlFilter *filter.Filter, logger log.Logger) Service {
+
+	reserved := make(map[string]struct{})
+	for _, words := range [][]string{reservedWords, conf.General.ReservedWords} {
+		for _, w := range words {
+			reserved[strings.ToLower(w)] = struct{}{}
+		}
+	}
 
 	return &basicService{
-		config: conf,
-		dao:    dao.New(db, re),
-		logger: logger,
+		config:   conf,
+		dao:      dao.New(store),
+		domains:  newShortDomains(conf.General.ShortDomain, conf.General.Domains, conf.General.RedirectPrefix, conf.UTM),
+		reserved: reserved,
+		filter:   urlFilter,
+		logger:   logger,
 	}
 }
 
 type basicService struct {
-	dao    *dao.Dao
-	config *config.Config
-	logger log.Logger
+	dao      *dao.Dao
+	config   *config.Config
+	domains  *shortDomains
+	reserved map[string]struct{}
+	filter   *filter.Filter
+	logger   log.Logger
+}
+
+// isReserved reports whether the code is a reserved word, in any case.
+func (s *basicService) isReserved(code string) bool {
+	_, ok := s.reserved[strings.ToLower(code)]
+	return ok
+}
+
+// checkURL rejects the normalized long URL going to a blocked destination.
+func (s *basicService) checkURL(longURL string) error {
+	if err := s.filter.Check(longURL); err != nil {
+		return fmt.Errorf("%w: %v", ErrBlockedURL, err)
+	}
+	return nil
+}
+
+// newLink returns the link, which is marked as blocked if its destination is
+// blocked after it is created.
+func (s *basicService) newLink(idKey string, l *dao.Link) *Link {
+	link := newLink(s.domains.shortURL(idKey), l)
+	link.Key = idKey
+	link.Blocked = s.filter.Check(l.LongURL) != nil
+	return link
 }
 
 func (s *basicService) convertToBase62Str(id int64) string {
@@ -73,40 +157,443 @@ func (s *basicService) convertToBase62Str(id int64) string {
 	return base62Str
 }
 
-func (s *basicService) Create(_ context.Context, longURL string) (string, error) {
+// bound returns the short domain which the caller is bound to, nil if the
+// caller is not bound. It is the only check of the domains of the callers,
+// for the links and the keys alike.
+func (s *basicService) bound(ctx context.Context) (*shortDomain, error) {
+	p := auth.PrincipalFromContext(ctx)
+	if p == nil || p.Domain == "" {
+		return nil, nil
+	}
+	return s.domains.lookup(p.Domain)
+}
+
+// domain returns the short domain of the links created by the caller. The
+// caller bound to a domain may only create the links of it.
+func (s *basicService) domain(ctx context.Context, name string) (*shortDomain, error) {
+	bound, err := s.bound(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if bound == nil {
+		return s.domains.lookup(name)
+	}
+	if name != "" {
+		d, err := s.domains.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		if d != bound {
+			return nil, fmt.Errorf("%w: the links of domain %s only", ErrForbidden, bound.host)
+		}
+	}
+	return bound, nil
+}
+
+// idKey returns the storage key of the short URL. The caller bound to a
+// domain may only access the links of it.
+func (s *basicService) idKey(ctx context.Context, shortURL string) (string, error) {
+	idKey := s.domains.idKey(shortURL)
+	bound, err := s.bound(ctx)
+	if err != nil || bound == nil {
+		return idKey, err
+	}
+	if d, _ := s.domains.of(idKey); d != bound {
+		return "", fmt.Errorf("%w: the links of domain %s only", ErrForbidden, bound.host)
+	}
+	return idKey, nil
+}
+
+// shortHost returns the short domain which the links listed by the caller
+// are limited to, nil if the caller is not bound to a domain.
+func (s *basicService) shortHost(ctx context.Context) (*string, error) {
+	bound, err := s.bound(ctx)
+	if err != nil || bound == nil {
+		return nil, err
+	}
+	return &bound.host, nil
+}
+
+func (s *basicService) expiresAt(opts CreateOptions, now time.Time) (int64, error) {
+	switch {
+	case opts.ExpiresIn != 0 && !opts.ExpiresAt.IsZero():
+		return 0, ErrInvalidExpiry
+	case opts.ExpiresIn < 0:
+		return 0, ErrInvalidExpiry
+	case opts.ExpiresIn > 0:
+		return now.Add(opts.ExpiresIn).Unix(), nil
+	case !opts.ExpiresAt.IsZero():
+		if !opts.ExpiresAt.After(now) {
+			return 0, ErrInvalidExpiry
+		}
+		return opts.ExpiresAt.Unix(), nil
+	}
+	return 0, nil
+}
+
+// prepareLink validates the long URL and the options, and returns the link to
+// be created in the domain. The utm parameters are merged onto the long URL.
+func (s *basicService) prepareLink(ctx context.Context, shortDomain *shortDomain, longURL string, opts CreateOptions, now time.Time) (*dao.Link, error) {
+	longURL, err := normalizeURL(longURL, s.config.General.AllowedSchemes, s.config.General.MaxURLLength)
+	if err != nil {
+		return nil, err
+	}
+	longURL, utm, err := mergeUTM(longURL, opts.UTM, shortDomain.utm, s.config.General.MaxURLLength)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkURL(longURL); err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := s.expiresAt(opts, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRedirect(opts.Redirect); err != nil {
+		return nil, err
+	}
+
+	link := &dao.Link{
+		LongURL:   longURL,
+		ExpiresAt: expiresAt,
+		CreatedAt: now.Unix(),
+		UTM:       utm,
+		Redirect:  opts.Redirect,
+	}
+
+	// the authenticated caller is the owner
+	if p := auth.PrincipalFromContext(ctx); p != nil {
+		opts.CreatedBy = p.Subject
+	}
+	if err := setMetadata(link, opts.Metadata); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+func (s *basicService) Create(ctx context.Context, longURL string, opts CreateOptions) (string, error) {
 	// shortDomain is configurable
-	shortDomain := s.config.General.ShortDomain
+	shortDomain, err := s.domain(ctx, opts.Domain)
+	if err != nil {
+		return "", err
+	}
 
-	shortIDKey := fmt.Sprintf("%x", md5.Sum([]byte(longURL)))
-	if shortURL, err := s.dao.GetShortURL(shortIDKey); err != nil {
+	now := time.Now()
+	link, err := s.prepareLink(ctx, shortDomain, longURL, opts, now)
+	if err != nil {
 		return "", err
-	} else if shortURL != "" {
-		return shortDomain + shortURL, nil
 	}
+	longURL = link.LongURL
 
-	nextID, err := s.dao.GenerateID()
+	if opts.Alias != "" {
+		return s.createAlias(shortDomain, link, opts.Alias)
+	}
+
+	// reuse the live link of the same long URL, expiry and metadata
+	shortIDKey := shortDomain.shortIDKey(longURL)
+	indexed, err := s.dao.GetShortURL(shortIDKey)
 	if err != nil {
 		return "", err
 	}
 
-	longIDKey := s.convertToBase62Str(nextID)
-	if err := s.dao.SetLongURL(longIDKey, longURL); err != nil {
+	var stale bool
+	if indexed != "" {
+		old, err := s.dao.GetLink(indexed)
+		if err != nil {
+			return "", err
+		}
+
+		stale = old == nil || old.LongURL != longURL || !old.Live(now)
+		if !stale && sameLink(old, link) {
+			return s.domains.shortURL(indexed), nil
+		}
+	}
+
+	// the generated ID may be taken by an alias or reserved, skip it
+	var code, longIDKey string
+	for {
+		nextID, err := s.dao.GenerateID()
+		if err != nil {
+			return "", err
+		}
+
+		code = s.convertToBase62Str(nextID)
+		if s.isReserved(code) {
+			continue
+		}
+		longIDKey = shortDomain.key(code)
+		ok, err := s.dao.AddLink(longIDKey, link)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			break
+		}
+	}
+
+	// the index prefers the link never expires
+	if indexed == "" || stale || link.ExpiresAt == 0 {
+		if err := s.dao.SetShortURL(shortIDKey, longIDKey); err != nil {
+			return "", err
+		}
+	}
+
+	return shortDomain.prefix + code, nil
+}
+
+func (s *basicService) createAlias(shortDomain *shortDomain, link *dao.Link, alias string) (string, error) {
+	if !aliasRegexp.MatchString(alias) {
+		return "", ErrInvalidAlias
+	}
+	if s.isReserved(alias) {
+		return "", ErrReservedAlias
+	}
+
+	ok, err := s.dao.AddLink(shortDomain.key(alias), link)
+	if err != nil {
 		return "", err
 	}
+	if ok {
+		return shortDomain.prefix + alias, nil
+	}
 
-	if err := s.dao.SetShortURL(shortIDKey, longIDKey); err != nil {
+	// creating the same alias twice is not a conflict
+	taken, err := s.dao.GetLink(shortDomain.key(alias))
+	if err != nil {
 		return "", err
 	}
+	if taken == nil || !sameLink(taken, link) {
+		return "", ErrAliasConflict
+	}
 
-	return shortDomain + longIDKey, nil
+	return shortDomain.prefix + alias, nil
 }
 
-func (s *basicService) Query(_ context.Context, shortURL string) (string, error) {
+// cleanShortURL removes the link from the index of its long URL, so the long
+// URL gets a new short code next time. It is called when the link is no longer
+// live or no longer goes to the long URL.
+func (s *basicService) cleanShortURL(longIDKey string, link *dao.Link) {
+	shortDomain, _ := s.domains.of(longIDKey)
+	shortIDKey := shortDomain.shortIDKey(link.LongURL)
+	indexed, err := s.dao.GetShortURL(shortIDKey)
+	if err == nil && indexed == longIDKey {
+		err = s.dao.DelShortURL(shortIDKey)
+	}
+	if err != nil {
+		s.logger.Warnw("clean short url error", "shortURL", longIDKey, "err", err)
+	}
+}
 
-	longIDKey := shortURL
-	longURL, err := s.dao.GetLongURL(longIDKey)
+// indexShortURL adds the live link to the index of its long URL, unless the
+// index has another live link.
+func (s *basicService) indexShortURL(longIDKey string, link *dao.Link, now time.Time) {
+	shortDomain, _ := s.domains.of(longIDKey)
+	shortIDKey := shortDomain.shortIDKey(link.LongURL)
+	indexed, err := s.dao.GetShortURL(shortIDKey)
+	if err == nil && indexed != "" && indexed != longIDKey {
+		var old *dao.Link
+		old, err = s.dao.GetLink(indexed)
+		if err == nil && old != nil && old.LongURL == link.LongURL && old.Live(now) {
+			return
+		}
+	}
+	if err == nil && indexed != longIDKey {
+		err = s.dao.SetShortURL(shortIDKey, longIDKey)
+	}
 	if err != nil {
-		return "", err
+		s.logger.Warnw("index short url error", "shortURL", longIDKey, "err", err)
+	}
+}
+
+func (s *basicService) Query(ctx context.Context, shortURL string) (*Link, error) {
+
+	longIDKey, err := s.idKey(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.dao.GetLink(longIDKey)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrNotFound
+	}
+
+	switch {
+	case link.DeletedAt > 0:
+		return nil, ErrDeleted
+	case link.Disabled:
+		return nil, ErrDisabled
+	case link.Expired(time.Now()):
+		s.cleanShortURL(longIDKey, link)
+		return nil, ErrExpired
+	}
+
+	return s.newLink(longIDKey, link), nil
+}
+
+func (s *basicService) Update(ctx context.Context, shortURL string, opts UpdateOptions) (*Link, error) {
+
+	longIDKey, err := s.idKey(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.dao.GetLink(longIDKey)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrNotFound
+	}
+	if link.DeletedAt > 0 {
+		return nil, ErrDeleted
+	}
+
+	old := *link
+	if opts.LongURL != nil {
+		longURL, err := normalizeURL(*opts.LongURL, s.config.General.AllowedSchemes, s.config.General.MaxURLLength)
+		if err != nil {
+			return nil, err
+		}
+		shortDomain, _ := s.domains.of(longIDKey)
+		longURL, utm, err := mergeUTM(longURL, UTM{}, shortDomain.utm, s.config.General.MaxURLLength)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkURL(longURL); err != nil {
+			return nil, err
+		}
+		link.LongURL = longURL
+		link.UTM = utm
+	}
+	if opts.Disabled != nil {
+		link.Disabled = *opts.Disabled
+	}
+	if opts.Redirect != nil {
+		if err := checkRedirect(*opts.Redirect); err != nil {
+			return nil, err
+		}
+		link.Redirect = *opts.Redirect
+	}
+	if err := updateMetadata(link, opts); err != nil {
+		return nil, err
+	}
+	if err := s.dao.SetLink(longIDKey, link); err != nil {
+		return nil, err
+	}
+
+	// keep the index of long URLs pointing to the live links only
+	now := time.Now()
+	if old.LongURL != link.LongURL || !link.Live(now) {
+		s.cleanShortURL(longIDKey, &old)
+	}
+	if link.Live(now) && (old.LongURL != link.LongURL || !old.Live(now)) {
+		s.indexShortURL(longIDKey, link, now)
+	}
+
+	return s.newLink(longIDKey, link), nil
+}
+
+func (s *basicService) Delete(ctx context.Context, shortURL string) error {
+
+	longIDKey, err := s.idKey(ctx, shortURL)
+	if err != nil {
+		return err
+	}
+	link, err := s.dao.GetLink(longIDKey)
+	if err != nil {
+		return err
+	}
+	if link == nil {
+		return ErrNotFound
 	}
-	return longURL, nil
+	if link.DeletedAt > 0 {
+		return nil
+	}
+
+	link.DeletedAt = time.Now().Unix()
+	if err := s.dao.SetLink(longIDKey, link); err != nil {
+		return err
+	}
+	s.cleanShortURL(longIDKey, link)
+
+	return nil
+}
+
+func (s *basicService) List(ctx context.Context, opts ListOptions) (*LinkList, error) {
+
+	o, err := s.listOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.ShortHost, err = s.shortHost(ctx); err != nil {
+		return nil, err
+	}
+
+	// one more entry tells whether there is a next page
+	limit := o.Limit
+	o.Limit++
+	entries, err := s.dao.ListLinks(o)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &LinkList{}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		list.NextCursor = encodeCursor(entries[limit-1].Cursor(o.Sort))
+	}
+	list.Links = make([]*ListItem, 0, len(entries))
+	for _, e := range entries {
+		list.Links = append(list.Links, &ListItem{
+			Link:   s.newLink(e.IDKey, e.Link),
+			Clicks: e.Clicks,
+		})
+	}
+
+	return list, nil
+}
+
+func (s *basicService) Clicks(ctx context.Context, shortURL string) (int64, error) {
+
+	longIDKey, err := s.idKey(ctx, shortURL)
+	if err != nil {
+		return 0, err
+	}
+	link, err := s.dao.GetLink(longIDKey)
+	if err != nil {
+		return 0, err
+	}
+	if link == nil {
+		return 0, ErrNotFound
+	}
+
+	return s.dao.GetClicks(longIDKey)
+}
+
+func (s *basicService) Stats(ctx context.Context, shortURL string) (*Stats, error) {
+
+	longIDKey, err := s.idKey(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.dao.GetLink(longIDKey)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrNotFound
+	}
+
+	clicks, err := s.dao.GetClicks(longIDKey)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.dao.GetStats(longIDKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStats(clicks, stats, time.Now()), nil
 }
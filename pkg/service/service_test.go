@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+func newTestConfig() *config.Config {
+	conf := &config.Config{}
+	conf.General.ShortDomain = "http://sh.url/"
+	conf.Server.Log.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	conf.Server.Log.Encoding = "console"
+	conf.Server.Log.OutputPaths = []string{"stderr"}
+	return conf
+}
+
+func newTestService(t *testing.T, conf *config.Config) (Service, dao.Storage) {
+	logger, err := log.NewLogger(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := dao.NewMemoryStorage()
+	return NewBasicService(conf, store, nil, logger), store
+}
+
+func TestCreateQuery(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	shortURL, err := svc.Create(ctx, "https://github.com/wifeng/short-url", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shortURL != "http://sh.url/2bI" {
+		t.Fatalf("got %s, want the code of the first ID", shortURL)
+	}
+
+	for _, s := range []string{shortURL, "2bI", "sh.url/2bI"} {
+		link, err := svc.Query(ctx, s)
+		if err != nil {
+			t.Fatalf("query %s: %v", s, err)
+		}
+		if link.ShortURL != shortURL || link.LongURL != "https://github.com/wifeng/short-url" {
+			t.Fatalf("query %s: got %+v", s, link)
+		}
+	}
+
+	if _, err := svc.Query(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if _, err := svc.Create(ctx, "ftp://example.com/", CreateOptions{}); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("got %v, want ErrInvalidURL", err)
+	}
+}
+
+func TestCreateDedup(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	first, err := svc.Create(ctx, "https://example.com/a", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := svc.Create(ctx, "https://example.com/a", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Fatalf("got %s, want the same link %s", again, first)
+	}
+
+	// a link of other options is another link
+	other, err := svc.Create(ctx, "https://example.com/a", CreateOptions{ExpiresIn: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Fatalf("got the same link %s of another expiry", other)
+	}
+}
+
+func TestCreateAlias(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	shortURL, err := svc.Create(ctx, "https://example.com/sale", CreateOptions{Alias: "sale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shortURL != "http://sh.url/sale" {
+		t.Fatalf("got %s", shortURL)
+	}
+
+	// the same alias of the same link is not a conflict
+	if again, err := svc.Create(ctx, "https://example.com/sale", CreateOptions{Alias: "sale"}); err != nil || again != shortURL {
+		t.Fatalf("got %s, %v, want %s", again, err, shortURL)
+	}
+
+	cases := []struct {
+		alias string
+		err   error
+	}{
+		{"sale", ErrAliasConflict},
+		{"admin", ErrReservedAlias},
+		{"a", ErrInvalidAlias},
+		{"no/slash", ErrInvalidAlias},
+	}
+	for _, c := range cases {
+		if _, err := svc.Create(ctx, "https://example.com/other", CreateOptions{Alias: c.alias}); !errors.Is(err, c.err) {
+			t.Errorf("alias %q: got %v, want %v", c.alias, err, c.err)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	svc, store := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	if _, err := svc.Create(ctx, "https://example.com/", CreateOptions{ExpiresIn: -time.Hour}); !errors.Is(err, ErrInvalidExpiry) {
+		t.Fatalf("got %v, want ErrInvalidExpiry", err)
+	}
+
+	shortURL, err := svc.Create(ctx, "https://example.com/", CreateOptions{ExpiresIn: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := svc.Query(ctx, shortURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.ExpiresAt == nil || time.Until(*link.ExpiresAt) > time.Hour {
+		t.Fatalf("got expiry %v", link.ExpiresAt)
+	}
+
+	// expire the link in the storage, rather than waiting for it
+	l, err := store.GetLink("2bI")
+	if err != nil || l == nil {
+		t.Fatalf("got %v, %v", l, err)
+	}
+	l.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	if err := store.SetLink("2bI", l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Query(ctx, shortURL); !errors.Is(err, ErrExpired) {
+		t.Fatalf("got %v, want ErrExpired", err)
+	}
+
+	// the expired link is not reused
+	renewed, err := svc.Create(ctx, "https://example.com/", CreateOptions{ExpiresIn: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed == shortURL {
+		t.Fatalf("got the expired link %s", renewed)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	svc, _ := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	shortURL, err := svc.Create(ctx, "https://example.com/", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, shortURL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Query(ctx, shortURL); !errors.Is(err, ErrDeleted) {
+		t.Fatalf("got %v, want ErrDeleted", err)
+	}
+
+	// deleting twice is fine, and the long URL gets a new link
+	if err := svc.Delete(ctx, shortURL); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	recreated, err := svc.Create(ctx, "https://example.com/", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recreated == shortURL {
+		t.Fatalf("got the deleted link %s", recreated)
+	}
+}
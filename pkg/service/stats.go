@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+const (
+	// statsTopN is the max entries of every top list
+	statsTopN = 10
+
+	// statsHours is how many recent hours are in the hourly series
+	statsHours = 48
+)
+
+// Stats is the report of the clicks of a short link.
+type Stats struct {
+	Clicks    int64   `json:"clicks"`
+	Visitors  int64   `json:"visitors"`
+	Days      []Point `json:"days"`
+	Hours     []Point `json:"hours"`
+	Referrers []Count `json:"referrers"`
+	Browsers  []Count `json:"browsers"`
+	Devices   []Count `json:"devices"`
+	Countries []Count `json:"countries"`
+}
+
+// Point is the clicks of a day or an hour, Time is in UTC.
+type Point struct {
+	Time   string `json:"time"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Count is the clicks of a referrer, browser, device or country.
+type Count struct {
+	Name   string `json:"name"`
+	Clicks int64  `json:"clicks"`
+}
+
+// GroupOptions collects the parameter grouped by and the filters of
+// GroupStats, the filters are the ones of ListOptions.
+type GroupOptions struct {
+	// By is one of the utm parameters, UTMCampaign if empty.
+	By string
+
+	Tag           string
+	CreatedBy     string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	LongURL       string
+	Domain        string
+}
+
+// Group is the links of a value of the grouped parameter, and their clicks.
+type Group struct {
+	Name   string `json:"name"`
+	Links  int64  `json:"links"`
+	Clicks int64  `json:"clicks"`
+}
+
+// GroupStats groups the links matching the filters by the utm parameter, the
+// links without it are in the group of the empty name. The groups are sorted
+// by clicks. It scans the links like List without a page.
+func (s *basicService) GroupStats(ctx context.Context, opts GroupOptions) ([]*Group, error) {
+	by := opts.By
+	if by == "" {
+		by = UTMCampaign
+	}
+	if !isUTMParam(by) {
+		return nil, fmt.Errorf("%w: unknown group %q", ErrInvalidRequest, opts.By)
+	}
+
+	o := dao.ListOptions{
+		Tag:       strings.TrimSpace(opts.Tag),
+		CreatedBy: opts.CreatedBy,
+		LongURL:   opts.LongURL,
+		Domain:    strings.ToLower(strings.TrimSpace(opts.Domain)),
+	}
+	if !opts.CreatedAfter.IsZero() {
+		o.CreatedAfter = opts.CreatedAfter.Unix()
+	}
+	if !opts.CreatedBefore.IsZero() {
+		o.CreatedBefore = opts.CreatedBefore.Unix()
+	}
+	shortHost, err := s.shortHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o.ShortHost = shortHost
+	entries, err := s.dao.ListLinks(o)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*Group{}
+	groups := []*Group{}
+	for _, e := range entries {
+		var name string
+		if e.Link.UTM != nil {
+			name = UTM(*e.Link.UTM).get(by)
+		}
+		g, ok := byName[name]
+		if !ok {
+			g = &Group{Name: name}
+			byName[name] = g
+			groups = append(groups, g)
+		}
+		g.Links++
+		g.Clicks += e.Clicks
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Clicks != groups[j].Clicks {
+			return groups[i].Clicks > groups[j].Clicks
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
+
+func newStats(clicks int64, s *dao.Stats, now time.Time) *Stats {
+	minHour := now.UTC().Add(-statsHours * time.Hour).Format("2006-01-02T15")
+	return &Stats{
+		Clicks:    clicks,
+		Visitors:  s.Visitors,
+		Days:      series(s.Days, ""),
+		Hours:     series(s.Hours, minHour),
+		Referrers: top(s.Referrers, "direct"),
+		Browsers:  top(s.Browsers, "unknown"),
+		Devices:   top(s.Devices, "unknown"),
+		Countries: top(s.Countries, "unknown"),
+	}
+}
+
+// series returns the points sorted by time, the ones before min are skipped.
+func series(m map[string]int64, min string) []Point {
+	points := make([]Point, 0, len(m))
+	for k, v := range m {
+		if k < min {
+			continue
+		}
+		points = append(points, Point{Time: k, Clicks: v})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time < points[j].Time
+	})
+	return points
+}
+
+// top returns the first statsTopN counts by clicks, the empty name is
+// reported as empty.
+func top(m map[string]int64, empty string) []Count {
+	counts := make([]Count, 0, len(m))
+	for k, v := range m {
+		if k == "" {
+			k = empty
+		}
+		counts = append(counts, Count{Name: k, Clicks: v})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Clicks != counts[j].Clicks {
+			return counts[i].Clicks > counts[j].Clicks
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	if len(counts) > statsTopN {
+		counts = counts[:statsTopN]
+	}
+	return counts
+}
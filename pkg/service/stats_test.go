@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+func TestStats(t *testing.T) {
+	svc, store := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	shortURL, err := svc.Create(ctx, "https://example.com/", CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	old := now.Add(-72 * time.Hour)
+	clicks := []dao.Click{
+		{Time: now, ShortCode: "2bI", Referrer: "https://t.co/x", Browser: "Chrome", Device: "desktop", Country: "US", IPHash: "a"},
+		{Time: now, ShortCode: "2bI", Browser: "Chrome", Device: "mobile", Country: "US", IPHash: "a"},
+		{Time: old, ShortCode: "2bI", Referrer: "https://t.co/y", Browser: "Firefox", IPHash: "b"},
+	}
+	if err := store.AddClickEvents(clicks); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IncrClicks("2bI", int64(len(clicks))); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := svc.Clicks(ctx, shortURL); err != nil || n != 3 {
+		t.Fatalf("got %d, %v clicks, want 3", n, err)
+	}
+	stats, err := svc.Stats(ctx, shortURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Clicks != 3 || stats.Visitors != 2 {
+		t.Fatalf("got %d clicks of %d visitors", stats.Clicks, stats.Visitors)
+	}
+	days := []Point{{old.Format("2006-01-02"), 1}, {now.Format("2006-01-02"), 2}}
+	if !reflect.DeepEqual(stats.Days, days) {
+		t.Fatalf("got days %v, want %v", stats.Days, days)
+	}
+	// the hours before the last 48 are skipped
+	if hours := []Point{{now.Format("2006-01-02T15"), 2}}; !reflect.DeepEqual(stats.Hours, hours) {
+		t.Fatalf("got hours %v, want %v", stats.Hours, hours)
+	}
+	if referrers := []Count{{"t.co", 2}, {"direct", 1}}; !reflect.DeepEqual(stats.Referrers, referrers) {
+		t.Fatalf("got referrers %v, want %v", stats.Referrers, referrers)
+	}
+	if countries := []Count{{"US", 2}, {"unknown", 1}}; !reflect.DeepEqual(stats.Countries, countries) {
+		t.Fatalf("got countries %v, want %v", stats.Countries, countries)
+	}
+
+	if _, err := svc.Stats(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if _, err := svc.Clicks(ctx, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTop(t *testing.T) {
+	m := map[string]int64{"": 1}
+	for i := 0; i < statsTopN+2; i++ {
+		m[string(rune('a'+i))] = int64(i + 2)
+	}
+	counts := top(m, "direct")
+	if len(counts) != statsTopN || counts[0].Name != string(rune('a'+statsTopN+1)) {
+		t.Fatalf("got %v, want the top %d by clicks", counts, statsTopN)
+	}
+	if counts := top(map[string]int64{"": 2, "b": 2, "a": 2}, "direct"); !reflect.DeepEqual(counts, []Count{{"a", 2}, {"b", 2}, {"direct", 2}}) {
+		t.Fatalf("got %v, want the ties by name", counts)
+	}
+}
+
+func TestGroupStats(t *testing.T) {
+	svc, store := newTestService(t, newTestConfig())
+	ctx := context.Background()
+
+	for i, c := range []struct {
+		campaign string
+		clicks   int64
+	}{{"spring", 3}, {"spring", 4}, {"fall", 10}, {"", 1}} {
+		shortURL, err := svc.Create(ctx, "https://example.com/"+string(rune('a'+i)), CreateOptions{UTM: UTM{Campaign: c.campaign}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.IncrClicks(shortURL[len("http://sh.url/"):], c.clicks); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := svc.GroupStats(ctx, GroupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Group{{"fall", 1, 10}, {"spring", 2, 7}, {"", 1, 1}}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("got %v, want %v", groups, want)
+	}
+
+	if groups, err := svc.GroupStats(ctx, GroupOptions{By: UTMSource}); err != nil || len(groups) != 1 || groups[0].Links != 4 {
+		t.Fatalf("got %v, %v, want a group of all", groups, err)
+	}
+	if _, err := svc.GroupStats(ctx, GroupOptions{By: "utm_id"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("got %v, want ErrInvalidRequest", err)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	// default of the long URL config
+	defaultMaxURLLength = 2048
+)
+
+var (
+	defaultAllowedSchemes = []string{"http", "https"}
+
+	defaultPorts = map[string]string{
+		"http":  "80",
+		"https": "443",
+	}
+)
+
+// normalizeURL validates the long URL, and returns the canonical form of it,
+// so the same URL written differently gets the same short code.
+//
+// The scheme and host are lowercased, the host is converted to punycode, the
+// default port is removed and the empty path becomes "/". The URLs with user
+// info are rejected, "https://paypal.com@evil.example/" goes to evil.example.
+func normalizeURL(rawURL string, allowedSchemes []string, maxLength int) (string, error) {
+	if len(allowedSchemes) == 0 {
+		allowedSchemes = defaultAllowedSchemes
+	}
+	if maxLength <= 0 {
+		maxLength = defaultMaxURLLength
+	}
+
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidURL)
+	}
+	if len(rawURL) > maxLength {
+		return "", fmt.Errorf("%w: longer than %d", ErrInvalidURL, maxLength)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return "", fmt.Errorf("%w: not an absolute url", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("%w: user info is not allowed", ErrInvalidURL)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	var allowed bool
+	for _, scheme := range allowedSchemes {
+		if u.Scheme == strings.ToLower(scheme) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("%w: scheme %s is not allowed", ErrInvalidURL, u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if net.ParseIP(host) == nil {
+		if host, err = idna.Lookup.ToASCII(host); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+		}
+	}
+
+	port := u.Port()
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+	}
+
+	normalized := u.String()
+	if len(normalized) > maxLength {
+		return "", fmt.Errorf("%w: longer than %d", ErrInvalidURL, maxLength)
+	}
+	return normalized, nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/WiFeng/short-url/pkg/dao"
+)
+
+const (
+	// limit of every utm parameter
+	maxUTMLength = 128
+)
+
+// utm parameters, they are the names of the query parameters too
+const (
+	UTMSource   = "utm_source"
+	UTMMedium   = "utm_medium"
+	UTMCampaign = "utm_campaign"
+	UTMTerm     = "utm_term"
+	UTMContent  = "utm_content"
+)
+
+// UTM is the utm parameters of the long URL of a link, for the analytics of
+// campaigns.
+type UTM struct {
+	Source   string `json:"source,omitempty"`
+	Medium   string `json:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty"`
+	Term     string `json:"term,omitempty"`
+	Content  string `json:"content,omitempty"`
+}
+
+// utmParams are the utm parameters in the order of the fields of UTM.
+var utmParams = []string{UTMSource, UTMMedium, UTMCampaign, UTMTerm, UTMContent}
+
+// values returns the fields in the order of utmParams.
+func (u *UTM) values() []*string {
+	return []*string{&u.Source, &u.Medium, &u.Campaign, &u.Term, &u.Content}
+}
+
+// get returns the value of the parameter, empty for the unknown ones.
+func (u UTM) get(param string) string {
+	values := u.values()
+	for i, p := range utmParams {
+		if p == param {
+			return *values[i]
+		}
+	}
+	return ""
+}
+
+func isUTMParam(param string) bool {
+	for _, p := range utmParams {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeUTM merges the utm parameters onto the normalized long URL. The given
+// ones replace the ones of the URL, and the defaults are added only if the URL
+// has none of them. It returns the URL and the utm parameters it ends up with.
+func mergeUTM(longURL string, utm UTM, defaults UTM, maxLength int) (string, *dao.UTM, error) {
+	if maxLength <= 0 {
+		maxLength = defaultMaxURLLength
+	}
+	given := utm.values()
+	for i, v := range given {
+		if err := checkLength(utmParams[i], *v, maxUTMLength); err != nil {
+			return "", nil, err
+		}
+	}
+
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	query := u.Query()
+	defs := defaults.values()
+	var params, vals []string
+	for i, param := range utmParams {
+		switch v := *given[i]; {
+		case v != "" && (len(query[param]) != 1 || query.Get(param) != v):
+			query.Set(param, v)
+			params, vals = append(params, param), append(vals, v)
+		case v == "" && *defs[i] != "" && query.Get(param) == "":
+			query.Set(param, *defs[i])
+			params, vals = append(params, param), append(vals, *defs[i])
+		}
+	}
+	if len(params) > 0 {
+		u.RawQuery = setQuery(u.RawQuery, params, vals)
+		longURL = u.String()
+		if len(longURL) > maxLength {
+			return "", nil, fmt.Errorf("%w: longer than %d", ErrInvalidURL, maxLength)
+		}
+	}
+
+	var applied dao.UTM
+	values := (*UTM)(&applied).values()
+	for i, param := range utmParams {
+		*values[i] = query.Get(param)
+	}
+	if applied == (dao.UTM{}) {
+		return longURL, nil, nil
+	}
+	return longURL, &applied, nil
+}
+
+// setQuery sets the parameters in the raw query. The other pairs are kept as
+// they are, in their order and escaping, so the signed URLs stay valid. A set
+// parameter replaces its first pair and drops the others, or is appended.
+func setQuery(rawQuery string, params []string, values []string) string {
+	index := make(map[string]int, len(params))
+	for i, param := range params {
+		index[param] = i
+	}
+
+	var pairs []string
+	set := make([]bool, len(params))
+	if rawQuery != "" {
+		for _, pair := range strings.Split(rawQuery, "&") {
+			key := pair
+			if i := strings.IndexByte(key, '='); i >= 0 {
+				key = key[:i]
+			}
+			if k, err := url.QueryUnescape(key); err == nil {
+				key = k
+			}
+			i, ok := index[key]
+			switch {
+			case !ok:
+				pairs = append(pairs, pair)
+			case !set[i]:
+				pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(values[i]))
+				set[i] = true
+			}
+		}
+	}
+	for i, param := range params {
+		if !set[i] {
+			pairs = append(pairs, url.QueryEscape(param)+"="+url.QueryEscape(values[i]))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestMergeUTM(t *testing.T) {
+	// a presigned URL, its pairs are neither sorted nor escaped by Encode
+	const signed = "https://bucket.s3.amazonaws.com/key?X-Amz-Signature=ab%2Ccd&X-Amz-Date=20200901T000000Z&q=a+b&list=1,2"
+
+	cases := []struct {
+		name     string
+		longURL  string
+		utm      UTM
+		defaults UTM
+		want     string
+		campaign string
+	}{
+		{
+			name:    "signed untouched",
+			longURL: signed,
+			want:    signed,
+		},
+		{
+			name:     "signed appended",
+			longURL:  signed,
+			utm:      UTM{Campaign: "spring sale"},
+			want:     signed + "&utm_campaign=spring+sale",
+			campaign: "spring sale",
+		},
+		{
+			name:     "replaced in place",
+			longURL:  "https://example.com/?b=2&utm_campaign=old&a=%2C&utm_campaign=older",
+			utm:      UTM{Campaign: "new"},
+			want:     "https://example.com/?b=2&utm_campaign=new&a=%2C",
+			campaign: "new",
+		},
+		{
+			name:     "same kept",
+			longURL:  "https://example.com/?z=1&utm_campaign=same&a=1",
+			utm:      UTM{Campaign: "same"},
+			want:     "https://example.com/?z=1&utm_campaign=same&a=1",
+			campaign: "same",
+		},
+		{
+			name:     "defaults of the missing",
+			longURL:  "https://example.com/?z=1&utm_source=mail",
+			defaults: UTM{Source: "brand", Medium: "social"},
+			want:     "https://example.com/?z=1&utm_source=mail&utm_medium=social",
+		},
+		{
+			name:    "no query",
+			longURL: "https://example.com/",
+			utm:     UTM{Source: "a&b"},
+			want:    "https://example.com/?utm_source=a%26b",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, applied, err := mergeUTM(c.longURL, c.utm, c.defaults, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Fatalf("got %s, want %s", got, c.want)
+			}
+			if c.campaign != "" && (applied == nil || applied.Campaign != c.campaign) {
+				t.Fatalf("got utm %+v, want campaign %s", applied, c.campaign)
+			}
+		})
+	}
+}
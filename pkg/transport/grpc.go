@@ -0,0 +1,694 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	kitot "github.com/go-kit/kit/tracing/opentracing"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+
+	"github.com/WiFeng/short-url/pb"
+	"github.com/WiFeng/short-url/pkg/auth"
+	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/endpoint"
+	"github.com/WiFeng/short-url/pkg/ratelimit"
+	"github.com/WiFeng/short-url/pkg/service"
+)
+
+type grpcServer struct {
+	create      kitgrpc.Handler
+	createBatch kitgrpc.Handler
+	query       kitgrpc.Handler
+	update      kitgrpc.Handler
+	delete      kitgrpc.Handler
+	list        kitgrpc.Handler
+	clicks      kitgrpc.Handler
+	stats       kitgrpc.Handler
+	groupStats  kitgrpc.Handler
+	qrCode      kitgrpc.Handler
+
+	issueKey  kitgrpc.Handler
+	revokeKey kitgrpc.Handler
+}
+
+// NewGRPCServer makes a set of endpoints available as a gRPC ShortURLServer.
+func NewGRPCServer(endpoints endpoint.Endpoints, logger log.Logger) pb.ShortURLServer {
+	options := []kitgrpc.ServerOption{
+		kitgrpc.ServerErrorLogger(logger),
+		kitgrpc.ServerAfter(afterGRPCHandler),
+	}
+
+	return &grpcServer{
+		create: kitgrpc.NewServer(
+			endpoints.CreateEndpoint,
+			decodeGRPCCreateRequest,
+			encodeGRPCCreateResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("Create", logger)))...,
+		),
+		createBatch: kitgrpc.NewServer(
+			endpoints.CreateBatchEndpoint,
+			decodeGRPCCreateBatchRequest,
+			encodeGRPCCreateBatchResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("CreateBatch", logger)))...,
+		),
+		query: kitgrpc.NewServer(
+			endpoints.QueryEndpoint,
+			decodeGRPCQueryRequest,
+			encodeGRPCQueryResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("Query", logger)))...,
+		),
+		update: kitgrpc.NewServer(
+			endpoints.UpdateEndpoint,
+			decodeGRPCUpdateRequest,
+			encodeGRPCUpdateResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("Update", logger)))...,
+		),
+		delete: kitgrpc.NewServer(
+			endpoints.DeleteEndpoint,
+			decodeGRPCQueryRequest,
+			encodeGRPCDeleteResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("Delete", logger)))...,
+		),
+		list: kitgrpc.NewServer(
+			endpoints.ListEndpoint,
+			decodeGRPCListRequest,
+			encodeGRPCListResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("List", logger)))...,
+		),
+		clicks: kitgrpc.NewServer(
+			endpoints.ClicksEndpoint,
+			decodeGRPCQueryRequest,
+			encodeGRPCClicksResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("Clicks", logger)))...,
+		),
+		stats: kitgrpc.NewServer(
+			endpoints.StatsEndpoint,
+			decodeGRPCQueryRequest,
+			encodeGRPCStatsResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("Stats", logger)))...,
+		),
+		groupStats: kitgrpc.NewServer(
+			endpoints.GroupStatsEndpoint,
+			decodeGRPCGroupStatsRequest,
+			encodeGRPCGroupStatsResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("GroupStats", logger)))...,
+		),
+		qrCode: kitgrpc.NewServer(
+			endpoints.QRCodeEndpoint,
+			decodeGRPCQRCodeRequest,
+			encodeGRPCQRCodeResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("QRCode", logger)))...,
+		),
+		issueKey: kitgrpc.NewServer(
+			endpoints.IssueKeyEndpoint,
+			decodeGRPCIssueKeyRequest,
+			encodeGRPCKeyResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("IssueKey", logger)))...,
+		),
+		revokeKey: kitgrpc.NewServer(
+			endpoints.RevokeKeyEndpoint,
+			decodeGRPCRevokeKeyRequest,
+			encodeGRPCKeyResponse,
+			append(options, kitgrpc.ServerBefore(beforeGRPCHandler("RevokeKey", logger)))...,
+		),
+	}
+}
+
+func (s *grpcServer) Create(ctx context.Context, req *pb.CreateRequest) (*pb.CreateReply, error) {
+	_, rep, err := s.create.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.CreateReply), nil
+}
+
+func (s *grpcServer) CreateBatch(ctx context.Context, req *pb.CreateBatchRequest) (*pb.CreateBatchReply, error) {
+	_, rep, err := s.createBatch.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.CreateBatchReply), nil
+}
+
+func (s *grpcServer) Query(ctx context.Context, req *pb.QueryRequest) (*pb.QueryReply, error) {
+	_, rep, err := s.query.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.QueryReply), nil
+}
+
+func (s *grpcServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.UpdateReply, error) {
+	_, rep, err := s.update.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.UpdateReply), nil
+}
+
+func (s *grpcServer) Delete(ctx context.Context, req *pb.QueryRequest) (*pb.DeleteReply, error) {
+	_, rep, err := s.delete.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.DeleteReply), nil
+}
+
+func (s *grpcServer) List(ctx context.Context, req *pb.ListRequest) (*pb.ListReply, error) {
+	_, rep, err := s.list.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.ListReply), nil
+}
+
+func (s *grpcServer) Clicks(ctx context.Context, req *pb.QueryRequest) (*pb.ClicksReply, error) {
+	_, rep, err := s.clicks.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.ClicksReply), nil
+}
+
+func (s *grpcServer) Stats(ctx context.Context, req *pb.QueryRequest) (*pb.StatsReply, error) {
+	_, rep, err := s.stats.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.StatsReply), nil
+}
+
+func (s *grpcServer) GroupStats(ctx context.Context, req *pb.GroupStatsRequest) (*pb.GroupStatsReply, error) {
+	_, rep, err := s.groupStats.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.GroupStatsReply), nil
+}
+
+func (s *grpcServer) QRCode(ctx context.Context, req *pb.QRCodeRequest) (*pb.QRCodeReply, error) {
+	_, rep, err := s.qrCode.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.QRCodeReply), nil
+}
+
+func (s *grpcServer) IssueKey(ctx context.Context, req *pb.IssueKeyRequest) (*pb.KeyReply, error) {
+	_, rep, err := s.issueKey.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.KeyReply), nil
+}
+
+func (s *grpcServer) RevokeKey(ctx context.Context, req *pb.RevokeKeyRequest) (*pb.KeyReply, error) {
+	_, rep, err := s.revokeKey.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rep.(*pb.KeyReply), nil
+}
+
+// beforeGRPCHandler joins the trace found in the metadata, and builds the
+// logger of the request, like beforeHandler of HTTP. The token of the caller
+// is taken from the authorization or x-api-key metadata.
+func beforeGRPCHandler(operationName string, logger log.Logger) kitgrpc.ServerRequestFunc {
+	toContext := kitot.GRPCToContext(opentracing.GlobalTracer(), operationName, logger)
+	return func(ctx context.Context, md metadata.MD) context.Context {
+		ctx = toContext(ctx, md)
+		ctx = buildLogger(ctx)
+
+		var token string
+		if v := md.Get("x-api-key"); len(v) > 0 {
+			token = v[0]
+		}
+		if v := md.Get("authorization"); len(v) > 0 && len(v[0]) > len(bearer) && strings.EqualFold(v[0][:len(bearer)], bearer) {
+			token = strings.TrimSpace(v[0][len(bearer):])
+		}
+		if token != "" {
+			ctx = auth.ContextWithToken(ctx, token)
+		}
+
+		if p, ok := peer.FromContext(ctx); ok {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				ctx = ratelimit.ContextWithClient(ctx, host)
+			}
+		}
+		return ctx
+	}
+}
+
+// code2grpc maps the error codes of service to gRPC codes, for the errors
+// which fail the endpoints rather than being answered in the replies.
+var code2grpc = map[string]codes.Code{
+	service.CodeInvalidRequest: codes.InvalidArgument,
+	service.CodeUnauthorized:   codes.Unauthenticated,
+	service.CodeForbidden:      codes.PermissionDenied,
+	service.CodeNotFound:       codes.NotFound,
+	service.CodeRateLimited:    codes.ResourceExhausted,
+}
+
+// grpcError converts the error to a gRPC status, the error code of service is
+// kept in the ErrorInfo detail, and the wait of rate limits in the RetryInfo
+// detail.
+func grpcError(err error) error {
+	code := service.ErrorCode(err)
+	c, ok := code2grpc[code]
+	if !ok {
+		c = codes.Internal
+	}
+	st, detailErr := status.New(c, err.Error()).WithDetails(&errdetails.ErrorInfo{Reason: code})
+	if detailErr != nil {
+		return err
+	}
+	var limitErr *endpoint.RateLimitError
+	if errors.As(err, &limitErr) {
+		if withRetry, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(limitErr.RetryAfter)}); err == nil {
+			st = withRetry
+		}
+	}
+	return st.Err()
+}
+
+func afterGRPCHandler(ctx context.Context, _ *metadata.MD, _ *metadata.MD) context.Context {
+	span := opentracing.SpanFromContext(ctx)
+	if span != nil {
+		span.Finish()
+	}
+	return ctx
+}
+
+// decodeGRPCCreateRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC create request to a user-domain create request. Primarily useful in a
+// server.
+func decodeGRPCCreateRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	return pb2CreateRequest(grpcReq.(*pb.CreateRequest)), nil
+}
+
+// decodeGRPCCreateBatchRequest is a transport/grpc.DecodeRequestFunc that
+// converts a gRPC batch create request to a user-domain batch create request.
+// Primarily useful in a server.
+func decodeGRPCCreateBatchRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CreateBatchRequest)
+	r := endpoint.CreateBatchRequest{Items: make([]endpoint.CreateRequest, len(req.Items))}
+	for i, item := range req.Items {
+		r.Items[i] = pb2CreateRequest(item)
+	}
+	return r, nil
+}
+
+func pb2CreateRequest(req *pb.CreateRequest) endpoint.CreateRequest {
+	r := endpoint.CreateRequest{
+		LongURL:   req.LongUrl,
+		Alias:     req.Alias,
+		Domain:    req.Domain,
+		ExpiresIn: req.ExpiresIn,
+		Redirect:  req.Redirect,
+		CreatedBy: req.CreatedBy,
+		Title:     req.Title,
+		Tags:      req.Tags,
+		Notes:     req.Notes,
+		Campaign:  req.Campaign,
+
+		UTMSource:   req.UtmSource,
+		UTMMedium:   req.UtmMedium,
+		UTMCampaign: req.UtmCampaign,
+		UTMTerm:     req.UtmTerm,
+		UTMContent:  req.UtmContent,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.AsTime()
+		r.ExpiresAt = &expiresAt
+	}
+	return r
+}
+
+// decodeGRPCQueryRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC query request to a user-domain query request. Primarily useful in a
+// server.
+func decodeGRPCQueryRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.QueryRequest)
+	return endpoint.QueryRequest{ShortURL: req.ShortUrl}, nil
+}
+
+// decodeGRPCUpdateRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC update request to a user-domain update request. Primarily useful in a
+// server.
+func decodeGRPCUpdateRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.UpdateRequest)
+	r := endpoint.UpdateRequest{
+		ShortURL: req.ShortUrl,
+		LongURL:  pb2str(req.LongUrl),
+		Redirect: pb2str(req.Redirect),
+		Title:    pb2str(req.Title),
+		Notes:    pb2str(req.Notes),
+		Campaign: pb2str(req.Campaign),
+	}
+	if req.Tags != nil {
+		tags := req.Tags.Values
+		r.Tags = &tags
+	}
+	if req.Disabled != nil {
+		disabled := req.Disabled.Value
+		r.Disabled = &disabled
+	}
+	return r, nil
+}
+
+// decodeGRPCListRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC list request to a user-domain list request. Primarily useful in a
+// server.
+func decodeGRPCListRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListRequest)
+	r := endpoint.ListRequest{
+		Tag:       req.Tag,
+		CreatedBy: req.CreatedBy,
+		LongURL:   req.LongUrl,
+		Domain:    req.Domain,
+		Sort:      req.Sort,
+		Order:     req.Order,
+		Cursor:    req.Cursor,
+		Limit:     int(req.Limit),
+	}
+	if req.CreatedAfter != nil {
+		r.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		r.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	return r, nil
+}
+
+// decodeGRPCGroupStatsRequest is a transport/grpc.DecodeRequestFunc that
+// converts a gRPC group stats request to a user-domain group stats request.
+// Primarily useful in a server.
+func decodeGRPCGroupStatsRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.GroupStatsRequest)
+	r := endpoint.GroupStatsRequest{
+		By:        req.GroupBy,
+		Tag:       req.Tag,
+		CreatedBy: req.CreatedBy,
+		LongURL:   req.LongUrl,
+		Domain:    req.Domain,
+	}
+	if req.CreatedAfter != nil {
+		r.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		r.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	return r, nil
+}
+
+// decodeGRPCQRCodeRequest is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC qr code request to a user-domain qr code request. Primarily useful in a
+// server.
+func decodeGRPCQRCodeRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.QRCodeRequest)
+	r := endpoint.QRCodeRequest{
+		ShortURL: req.ShortUrl,
+		QROptions: service.QROptions{
+			Format:     req.Format,
+			Size:       int(req.Size),
+			Level:      req.Level,
+			Foreground: req.Foreground,
+			Background: req.Background,
+		},
+	}
+	if req.Margin != nil {
+		margin := int(req.Margin.Value)
+		r.Margin = &margin
+	}
+	return r, nil
+}
+
+// decodeGRPCIssueKeyRequest is a transport/grpc.DecodeRequestFunc that converts
+// a gRPC issue key request to a user-domain issue key request. Primarily useful
+// in a server.
+func decodeGRPCIssueKeyRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.IssueKeyRequest)
+	return endpoint.IssueKeyRequest{Name: req.Name, Scopes: req.Scopes, Domain: req.Domain}, nil
+}
+
+// decodeGRPCRevokeKeyRequest is a transport/grpc.DecodeRequestFunc that
+// converts a gRPC revoke key request to a user-domain revoke key request.
+// Primarily useful in a server.
+func decodeGRPCRevokeKeyRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RevokeKeyRequest)
+	return endpoint.RevokeKeyRequest{ID: req.Id}, nil
+}
+
+// encodeGRPCCreateResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain create response to a gRPC create reply. Primarily useful in a
+// server.
+func encodeGRPCCreateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.CreateResponse)
+	return &pb.CreateReply{
+		ShortUrl: resp.ShortURL,
+		Err:      err2str(resp.Err),
+		Code:     err2errcode(resp.Err),
+	}, nil
+}
+
+// encodeGRPCCreateBatchResponse is a transport/grpc.EncodeResponseFunc that
+// converts a user-domain batch create response to a gRPC batch create reply.
+// Primarily useful in a server.
+func encodeGRPCCreateBatchResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.CreateBatchResponse)
+	rep := &pb.CreateBatchReply{
+		Results: make([]*pb.CreateReply, len(resp.Results)),
+		Err:     err2str(resp.Err),
+		Code:    err2errcode(resp.Err),
+	}
+	for i, r := range resp.Results {
+		rep.Results[i] = &pb.CreateReply{ShortUrl: r.ShortURL, Err: r.Error, Code: r.Code}
+	}
+	return rep, nil
+}
+
+// encodeGRPCQueryResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain query response to a gRPC query reply. Primarily useful in a
+// server.
+func encodeGRPCQueryResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.QueryResponse)
+	rep := &pb.QueryReply{
+		Link: link2pb(resp.Link),
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}
+	if resp.Link != nil {
+		rep.LongUrl = resp.LongURL
+	}
+	return rep, nil
+}
+
+// encodeGRPCUpdateResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain update response to a gRPC update reply. Primarily useful in a
+// server.
+func encodeGRPCUpdateResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.UpdateResponse)
+	return &pb.UpdateReply{
+		Link: link2pb(resp.Link),
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}, nil
+}
+
+// encodeGRPCDeleteResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain delete response to a gRPC delete reply. Primarily useful in a
+// server.
+func encodeGRPCDeleteResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.DeleteResponse)
+	return &pb.DeleteReply{
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}, nil
+}
+
+// encodeGRPCListResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain list response to a gRPC list reply. Primarily useful in a
+// server.
+func encodeGRPCListResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.ListResponse)
+	rep := &pb.ListReply{
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}
+	if resp.LinkList != nil {
+		rep.NextCursor = resp.NextCursor
+		for _, item := range resp.Links {
+			rep.Links = append(rep.Links, &pb.ListItem{Link: link2pb(item.Link), Clicks: item.Clicks})
+		}
+	}
+	return rep, nil
+}
+
+// encodeGRPCKeyResponse is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain key response to a gRPC key reply. Primarily useful in a server.
+func encodeGRPCKeyResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.KeyResponse)
+	rep := &pb.KeyReply{
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}
+	if k := resp.APIKey; k != nil {
+		rep.Id = k.ID
+		rep.Key = k.Key
+		rep.Name = k.Name
+		rep.Scopes = k.Scopes
+		rep.Domain = k.Domain
+		rep.CreatedAt = timestamppb.New(k.CreatedAt)
+		rep.CreatedBy = k.CreatedBy
+		if k.RevokedAt != nil {
+			rep.RevokedAt = timestamppb.New(*k.RevokedAt)
+		}
+	}
+	return rep, nil
+}
+
+func link2pb(link *service.Link) *pb.Link {
+	if link == nil {
+		return nil
+	}
+	l := &pb.Link{
+		ShortUrl:  link.ShortURL,
+		LongUrl:   link.LongURL,
+		CreatedBy: link.CreatedBy,
+		Title:     link.Title,
+		Tags:      link.Tags,
+		Notes:     link.Notes,
+		Campaign:  link.Campaign,
+		Disabled:  link.Disabled,
+		Blocked:   link.Blocked,
+		Redirect:  link.Redirect,
+	}
+	if u := link.UTM; u != nil {
+		l.Utm = &pb.UTM{Source: u.Source, Medium: u.Medium, Campaign: u.Campaign, Term: u.Term, Content: u.Content}
+	}
+	if link.ExpiresAt != nil {
+		l.ExpiresAt = timestamppb.New(*link.ExpiresAt)
+	}
+	if link.CreatedAt != nil {
+		l.CreatedAt = timestamppb.New(*link.CreatedAt)
+	}
+	return l
+}
+
+func pb2str(v *wrapperspb.StringValue) *string {
+	if v == nil {
+		return nil
+	}
+	s := v.Value
+	return &s
+}
+
+// encodeGRPCClicksResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain clicks response to a gRPC clicks reply. Primarily useful in a
+// server.
+func encodeGRPCClicksResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.ClicksResponse)
+	return &pb.ClicksReply{
+		Clicks: resp.Clicks,
+		Err:    err2str(resp.Err),
+		Code:   err2errcode(resp.Err),
+	}, nil
+}
+
+// encodeGRPCStatsResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain stats response to a gRPC stats reply. Primarily useful in a
+// server.
+func encodeGRPCStatsResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.StatsResponse)
+	rep := &pb.StatsReply{
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}
+	if s := resp.Stats; s != nil {
+		rep.Clicks = s.Clicks
+		rep.Visitors = s.Visitors
+		rep.Days = points2pb(s.Days)
+		rep.Hours = points2pb(s.Hours)
+		rep.Referrers = counts2pb(s.Referrers)
+		rep.Browsers = counts2pb(s.Browsers)
+		rep.Devices = counts2pb(s.Devices)
+		rep.Countries = counts2pb(s.Countries)
+	}
+	return rep, nil
+}
+
+// encodeGRPCGroupStatsResponse is a transport/grpc.EncodeResponseFunc that
+// converts a user-domain group stats response to a gRPC group stats reply.
+// Primarily useful in a server.
+func encodeGRPCGroupStatsResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.GroupStatsResponse)
+	rep := &pb.GroupStatsReply{
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}
+	for _, g := range resp.Groups {
+		rep.Groups = append(rep.Groups, &pb.Group{Name: g.Name, Links: g.Links, Clicks: g.Clicks})
+	}
+	return rep, nil
+}
+
+// encodeGRPCQRCodeResponse is a transport/grpc.EncodeResponseFunc that converts
+// a user-domain qr code response to a gRPC qr code reply. Primarily useful in a
+// server.
+func encodeGRPCQRCodeResponse(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(endpoint.QRCodeResponse)
+	rep := &pb.QRCodeReply{
+		Err:  err2str(resp.Err),
+		Code: err2errcode(resp.Err),
+	}
+	if c := resp.QRCode; c != nil {
+		rep.Data = c.Data
+		rep.ContentType = c.ContentType
+	}
+	return rep, nil
+}
+
+func points2pb(points []service.Point) []*pb.Point {
+	res := make([]*pb.Point, 0, len(points))
+	for _, p := range points {
+		res = append(res, &pb.Point{Time: p.Time, Clicks: p.Clicks})
+	}
+	return res
+}
+
+func counts2pb(counts []service.Count) []*pb.Count {
+	res := make([]*pb.Count, 0, len(counts))
+	for _, c := range counts {
+		res = append(res, &pb.Count{Name: c.Name, Clicks: c.Clicks})
+	}
+	return res
+}
+
+// These annoying helper functions are required to translate Go error types to
+// and from strings, which is the type we use in our IDLs to represent errors.
+
+func err2str(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func err2errcode(err error) string {
+	if err == nil {
+		return ""
+	}
+	return service.ErrorCode(err)
+}
@@ -6,9 +6,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html/template"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/opentracing/opentracing-go"
@@ -18,8 +25,13 @@ import (
 	kitendpoint "github.com/go-kit/kit/endpoint"
 	kithttp "github.com/go-kit/kit/transport/http"
 
+	"github.com/WiFeng/short-url/pkg/analytics"
+	"github.com/WiFeng/short-url/pkg/auth"
+	"github.com/WiFeng/short-url/pkg/core/config"
 	"github.com/WiFeng/short-url/pkg/core/log"
 	"github.com/WiFeng/short-url/pkg/endpoint"
+	"github.com/WiFeng/short-url/pkg/ratelimit"
+	"github.com/WiFeng/short-url/pkg/service"
 )
 
 var (
@@ -31,14 +43,79 @@ var (
 	ErrReponseAssert = errors.New("response assert error")
 )
 
+const (
+	// bearer is the scheme of the authorization header
+	bearer = "Bearer "
+
+	// legacyRedirectPrefix is the path of the redirects before it is
+	// configurable
+	legacyRedirectPrefix = "/x"
+)
+
+// defaultNotFoundPage is shown to browsers for unknown links, unless the
+// page is configured.
+var defaultNotFoundPage = []byte(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Link not found</title></head>
+<body><h1>Link not found</h1></body>
+</html>
+`)
+
+// defaultBlockedPage is the warning shown to browsers for the links to blocked
+// destinations, unless the page is configured.
+var defaultBlockedPage = []byte(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Warning: blocked link</title></head>
+<body><h1>Warning: this link has been blocked</h1><p>It leads to a site which is reported as unsafe.</p></body>
+</html>
+`)
+
+// defaultInterstitialPage is the template of the interstitial redirects,
+// unless the page is configured.
+var defaultInterstitialPage = []byte(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Delay}};url={{.URL}}">
+<title>{{if .Title}}{{.Title}}{{else}}Redirecting{{end}}</title>
+</head>
+<body>
+<p>Redirecting to <a href="{{.URL}}">{{.URL}}</a></p>
+<script>setTimeout(function () { location.replace({{.URL}}); }, {{.Delay}} * 1000);</script>
+</body>
+</html>
+`)
+
+// redirectStatus maps the types of redirects to http status.
+var redirectStatus = map[string]int{
+	"301": http.StatusMovedPermanently,
+	"302": http.StatusFound,
+	"307": http.StatusTemporaryRedirect,
+	"308": http.StatusPermanentRedirect,
+}
+
 // NewHTTPHandler returns an HTTP handler that makes a set of endpoints
 // available on predefined paths.
-func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger) http.Handler {
+func NewHTTPHandler(endpoints endpoint.Endpoints, conf *config.Config, logger log.Logger) http.Handler {
+	notFoundPage := readPage(conf.Server.HTTP.NotFoundPage, defaultNotFoundPage, logger)
+	blockedPage := readPage(conf.Server.HTTP.BlockedPage, defaultBlockedPage, logger)
+	interstitialPage := readTemplate(conf.Redirect.InterstitialPage, defaultInterstitialPage, logger)
+
+	redirectConf := conf.Redirect
+	if _, ok := redirectStatus[redirectConf.Type]; !ok && redirectConf.Type != service.RedirectInterstitial {
+		if redirectConf.Type != "" {
+			logger.Errorw("unknown redirect type, 302 is used", "type", redirectConf.Type)
+		}
+		redirectConf.Type = "302"
+	}
+
+	proxies := parseProxies(conf.Server.HTTP.TrustedProxies, logger)
+
 	r := mux.NewRouter()
 	options := []kithttp.ServerOption{
 		kithttp.ServerErrorEncoder(errorEncoder),
 		kithttp.ServerErrorLogger(logger),
-		kithttp.ServerBefore(beforeHandler),
+		kithttp.ServerBefore(beforeHandler, populateToken, proxies.populateClient),
 		kithttp.ServerAfter(afterHandler),
 	}
 
@@ -55,6 +132,13 @@ func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger) http.Handle
 		options...,
 	))
 
+	r.Methods("POST").Path("/admin/create/batch").Handler(kithttp.NewServer(
+		endpoints.CreateBatchEndpoint,
+		decodeHTTPCreateBatchRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
 	r.Methods("POST").Path("/admin/query").Handler(kithttp.NewServer(
 		endpoints.QueryEndpoint,
 		decodeHTTPQueryRequest,
@@ -62,39 +146,201 @@ func NewHTTPHandler(endpoints endpoint.Endpoints, logger log.Logger) http.Handle
 		options...,
 	))
 
-	r.Methods("GET").Path("/x/{id}").Handler(kithttp.NewServer(
+	r.Methods("POST").Path("/admin/update").Handler(kithttp.NewServer(
+		endpoints.UpdateEndpoint,
+		decodeHTTPUpdateRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/admin/delete").Handler(kithttp.NewServer(
+		endpoints.DeleteEndpoint,
+		decodeHTTPQueryRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/admin/links").Handler(kithttp.NewServer(
+		endpoints.ListEndpoint,
+		decodeHTTPListRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/admin/clicks").Handler(kithttp.NewServer(
+		endpoints.ClicksEndpoint,
+		decodeHTTPQueryRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/admin/stats/{id}").Handler(kithttp.NewServer(
+		endpoints.StatsEndpoint,
+		decodeHTTPStatsRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/admin/stats").Handler(kithttp.NewServer(
+		endpoints.GroupStatsEndpoint,
+		decodeHTTPGroupStatsRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("GET").Path("/admin/qr/{id}").Handler(kithttp.NewServer(
+		endpoints.QRCodeEndpoint,
+		decodeHTTPQRCodeRequest,
+		encodeHTTPQRCodeResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/admin/keys/issue").Handler(kithttp.NewServer(
+		endpoints.IssueKeyEndpoint,
+		decodeHTTPIssueKeyRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	r.Methods("POST").Path("/admin/keys/revoke").Handler(kithttp.NewServer(
+		endpoints.RevokeKeyEndpoint,
+		decodeHTTPRevokeKeyRequest,
+		encodeHTTPGenericResponse,
+		options...,
+	))
+
+	// the redirects are routed last, so the codes at the root never shadow the
+	// routes above. /x/ is kept for the links shared before the prefix. The qr
+	// codes of the links are at their paths with .png or .svg.
+	redirect := kithttp.NewServer(
 		endpoints.QueryAdvEndpoint,
 		decodeHTTPQueryAdvRequest,
-		encodeHTTPQueryAdvResponse,
+		newHTTPRedirectEncoder(redirectConf, interstitialPage),
+		append(options,
+			kithttp.ServerBefore(kithttp.PopulateRequestContext, proxies.populateVisitor),
+			kithttp.ServerErrorEncoder(newRedirectErrorEncoder(map[error][]byte{
+				service.ErrNotFound:   notFoundPage,
+				service.ErrBlockedURL: blockedPage,
+			})),
+		)...,
+	)
+	qrCode := kithttp.NewServer(
+		endpoints.QRCodeAdvEndpoint,
+		decodeHTTPQRCodeAdvRequest,
+		encodeHTTPQRCodeResponse,
 		options...,
-	))
+	)
+	prefix := redirectPrefix(conf.General.RedirectPrefix)
+	prefixes := []string{prefix}
+	if prefix != legacyRedirectPrefix {
+		prefixes = append(prefixes, legacyRedirectPrefix)
+	}
+	for _, prefix := range prefixes {
+		r.Methods("GET").Path(prefix + "/{id}.{format:png|svg}").Handler(qrCode)
+		r.Methods("GET").Path(prefix + "/{id}").Handler(redirect)
+	}
 
 	return r
 }
 
+// redirectPrefix returns the path of the redirects before the codes, empty for
+// the root.
+func redirectPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
 func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
-	w.WriteHeader(err2code(err))
-	json.NewEncoder(w).Encode(errorWrapper{Error: err.Error()})
+	code := err2code(err)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	var limitErr *endpoint.RateLimitError
+	if errors.As(err, &limitErr) {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(limitErr.RetryAfter.Seconds())), 10))
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(errorWrapper{Error: err.Error(), Code: service.ErrorCode(err)})
 }
 
-func err2code(err error) int {
-	//switch err {
-	//case service.ErrTwoZeroes, service.ErrMaxSizeExceeded, service.ErrIntOverflow:
-	//	return http.StatusBadRequest
-	//}
-	return http.StatusInternalServerError
+// readPage returns the html file at the path, or the built-in page if the path
+// is empty or unreadable.
+func readPage(fpath string, builtin []byte, logger log.Logger) []byte {
+	if fpath == "" {
+		return builtin
+	}
+	page, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		logger.Errorw("read page error, the built-in page is used", "path", fpath, "err", err)
+		return builtin
+	}
+	return page
+}
+
+// readTemplate returns the html/template at the path like readPage, the
+// built-in one is used if the file is not a valid template.
+func readTemplate(fpath string, builtin []byte, logger log.Logger) *template.Template {
+	page := readPage(fpath, builtin, logger)
+	t, err := template.New("page").Parse(string(page))
+	if err != nil {
+		logger.Errorw("parse page error, the built-in page is used", "path", fpath, "err", err)
+		t = template.Must(template.New("page").Parse(string(builtin)))
+	}
+	return t
+}
+
+// newRedirectErrorEncoder returns the error encoder of redirect, which shows
+// the html pages of the errors to browsers, e.g. for unknown links.
+func newRedirectErrorEncoder(pages map[error][]byte) kithttp.ErrorEncoder {
+	return func(ctx context.Context, err error, w http.ResponseWriter) {
+		accept, _ := ctx.Value(kithttp.ContextKeyRequestAccept).(string)
+		if !strings.Contains(accept, "text/html") {
+			errorEncoder(ctx, err, w)
+			return
+		}
+		for target, page := range pages {
+			if errors.Is(err, target) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(err2code(err))
+				w.Write(page)
+				return
+			}
+		}
+		errorEncoder(ctx, err, w)
+	}
+}
+
+// code2status maps the error codes of service to http status.
+var code2status = map[string]int{
+	service.CodeInvalidRequest: http.StatusBadRequest,
+	service.CodeInvalidURL:     http.StatusBadRequest,
+	service.CodeInvalidAlias:   http.StatusBadRequest,
+	service.CodeInvalidExpiry:  http.StatusBadRequest,
+	service.CodeBlockedURL:     http.StatusForbidden,
+	service.CodeUnauthorized:   http.StatusUnauthorized,
+	service.CodeForbidden:      http.StatusForbidden,
+	service.CodeNotFound:       http.StatusNotFound,
+	service.CodeAliasConflict:  http.StatusConflict,
+	service.CodeExpired:        http.StatusGone,
+	service.CodeDisabled:       http.StatusGone,
+	service.CodeDeleted:        http.StatusGone,
+	service.CodeRateLimited:    http.StatusTooManyRequests,
 }
 
-func errorDecoder(r *http.Response) error {
-	var w errorWrapper
-	if err := json.NewDecoder(r.Body).Decode(&w); err != nil {
-		return err
+func err2code(err error) int {
+	if status, ok := code2status[service.ErrorCode(err)]; ok {
+		return status
 	}
-	return errors.New(w.Error)
+	return http.StatusInternalServerError
 }
 
 type errorWrapper struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
 }
 
 func startSpan(ctx context.Context, r *http.Request) context.Context {
@@ -164,6 +410,108 @@ func syncLogger(ctx context.Context) context.Context {
 	return ctx
 }
 
+// populateVisitor puts the visitor of the request into the context, for the
+// analytics of redirects.
+func (p trustedProxies) populateVisitor(ctx context.Context, r *http.Request) context.Context {
+	return analytics.ContextWithVisitor(ctx, analytics.Visitor{
+		IP:        p.clientIP(r),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+	})
+}
+
+// populateToken puts the token of the caller into the context, it is taken
+// from the bearer authorization or the X-API-Key header.
+func populateToken(ctx context.Context, r *http.Request) context.Context {
+	token := r.Header.Get("X-API-Key")
+	if h := r.Header.Get("Authorization"); len(h) > len(bearer) && strings.EqualFold(h[:len(bearer)], bearer) {
+		token = strings.TrimSpace(h[len(bearer):])
+	}
+	if token == "" {
+		return ctx
+	}
+	return auth.ContextWithToken(ctx, token)
+}
+
+// populateClient puts the IP of the client into the context, for the rate
+// limits of anonymous callers.
+func (p trustedProxies) populateClient(ctx context.Context, r *http.Request) context.Context {
+	return ratelimit.ContextWithClient(ctx, p.clientIP(r))
+}
+
+// trustedProxies are the networks of the proxies whose forwarding headers
+// are trusted.
+type trustedProxies []*net.IPNet
+
+// parseProxies parses the IPs and CIDRs of the config, the invalid ones are
+// logged and skipped.
+func parseProxies(list []string, logger log.Logger) trustedProxies {
+	var p trustedProxies
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip != nil {
+			if v4 := ip.To4(); v4 != nil {
+				ip = v4
+			}
+			p = append(p, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			logger.Errorw("invalid trusted proxy, skipped", "proxy", s, "err", err)
+			continue
+		}
+		p = append(p, ipNet)
+	}
+	return p
+}
+
+func (p trustedProxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range p {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client. The peer is the client unless it is
+// a trusted proxy, then X-Forwarded-For is walked from the right, and the
+// first hop which is not a trusted proxy is the client, the left ones may be
+// forged by it. X-Real-IP is used if the proxy sets no X-Forwarded-For.
+func (p trustedProxies) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !p.trusted(ip) {
+		return ip
+	}
+
+	xff := r.Header.Values("X-Forwarded-For")
+	if len(xff) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+		return ip
+	}
+	hops := strings.Split(strings.Join(xff, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !p.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
 func beforeHandler(ctx context.Context, r *http.Request) context.Context {
 	ctx = startSpan(ctx, r)
 	ctx = buildLogger(ctx)
@@ -181,16 +529,106 @@ func afterHandler(ctx context.Context, w http.ResponseWriter) context.Context {
 // server.
 func decodeHTTPCreateRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoint.CreateRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	return req, err
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+	}
+	return req, nil
+}
+
+func decodeHTTPCreateBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.CreateBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+	}
+	return req, nil
 }
 
 func decodeHTTPQueryRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoint.QueryRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	return req, err
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+	}
+	return req, nil
+}
+
+func decodeHTTPUpdateRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+	}
+	return req, nil
+}
+
+// decodeHTTPListRequest decodes the list request from the query string, the
+// times are in RFC 3339.
+func decodeHTTPListRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	req := endpoint.ListRequest{
+		Tag:       q.Get("tag"),
+		CreatedBy: q.Get("created_by"),
+		LongURL:   q.Get("long_url"),
+		Domain:    q.Get("domain"),
+		Sort:      q.Get("sort"),
+		Order:     q.Get("order"),
+		Cursor:    q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("created_after"); v != "" {
+		if req.CreatedAfter, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+		}
+	}
+	if v := q.Get("created_before"); v != "" {
+		if req.CreatedBefore, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+		}
+	}
+	return req, nil
+}
+
+// decodeHTTPGroupStatsRequest decodes the group stats request from the query
+// string, the filters are decoded like the list request.
+func decodeHTTPGroupStatsRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	req, err := decodeHTTPListRequest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	list := req.(endpoint.ListRequest)
+	return endpoint.GroupStatsRequest{
+		By:            r.URL.Query().Get("group_by"),
+		Tag:           list.Tag,
+		CreatedBy:     list.CreatedBy,
+		CreatedAfter:  list.CreatedAfter,
+		CreatedBefore: list.CreatedBefore,
+		LongURL:       list.LongURL,
+		Domain:        list.Domain,
+	}, nil
+}
+
+func decodeHTTPIssueKeyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.IssueKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+	}
+	return req, nil
+}
+
+func decodeHTTPRevokeKeyRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req endpoint.RevokeKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+	}
+	return req, nil
 }
 
+// decodeHTTPQueryAdvRequest resolves the code in the short domain of the Host
+// header, the codes of the other hosts are of the default domain.
 func decodeHTTPQueryAdvRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req endpoint.QueryRequest
 	vars := mux.Vars(r)
@@ -198,19 +636,85 @@ func decodeHTTPQueryAdvRequest(_ context.Context, r *http.Request) (interface{},
 	if !ok {
 		return nil, ErrBadRouting
 	}
-	req.ShortURL = id
+	req.ShortURL = r.Host + "/" + id
 	return req, nil
 }
 
-// encodeHTTPGenericRequest is a transport/http.EncodeRequestFunc that
-// JSON-encodes any request to the request body. Primarily useful in a client.
-func encodeHTTPGenericRequest(_ context.Context, r *http.Request, request interface{}) error {
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(request); err != nil {
-		return err
+// decodeHTTPStatsRequest decodes the stats request of the code in the short
+// domain of the domain parameter, or of the Host header of the request.
+func decodeHTTPStatsRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		return nil, ErrBadRouting
 	}
-	r.Body = ioutil.NopCloser(&buf)
-	return nil
+	return endpoint.QueryRequest{ShortURL: adminHost(r) + "/" + id}, nil
+}
+
+// decodeHTTPQRCodeRequest decodes the qr code request of the code in the short
+// domain of the domain parameter, or of the Host header like the stats.
+func decodeHTTPQRCodeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	q := r.URL.Query()
+	opts, err := decodeHTTPQROptions(q)
+	if err != nil {
+		return nil, err
+	}
+	opts.Format = q.Get("format")
+	return endpoint.QRCodeRequest{ShortURL: adminHost(r) + "/" + id, QROptions: opts}, nil
+}
+
+// adminHost returns the short domain of the admin request of a code, the
+// short_domain parameter, or the Host header if absent. It is not the domain
+// parameter, which filters the links by their long URLs.
+func adminHost(r *http.Request) string {
+	if domain := r.URL.Query().Get("short_domain"); domain != "" {
+		return strings.TrimSuffix(domain, "/")
+	}
+	return r.Host
+}
+
+// decodeHTTPQRCodeAdvRequest decodes the qr code request of the public path,
+// the code is resolved like the redirects and the format is the extension.
+func decodeHTTPQRCodeAdvRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+	id, ok := vars["id"]
+	if !ok {
+		return nil, ErrBadRouting
+	}
+	opts, err := decodeHTTPQROptions(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	opts.Format = vars["format"]
+	return endpoint.QRCodeRequest{ShortURL: r.Host + "/" + id, QROptions: opts}, nil
+}
+
+// decodeHTTPQROptions decodes the options of the image from the query string,
+// the colors may be without #.
+func decodeHTTPQROptions(q url.Values) (service.QROptions, error) {
+	opts := service.QROptions{
+		Level:      q.Get("level"),
+		Foreground: q.Get("foreground"),
+		Background: q.Get("background"),
+	}
+	if v := q.Get("size"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+		}
+		opts.Size = size
+	}
+	if v := q.Get("margin"); v != "" {
+		margin, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %v", service.ErrInvalidRequest, err)
+		}
+		opts.Margin = &margin
+	}
+	return opts, nil
 }
 
 // encodeHTTPGenericResponse is a transport/http.EncodeResponseFunc that encodes
@@ -224,21 +728,101 @@ func encodeHTTPGenericResponse(ctx context.Context, w http.ResponseWriter, respo
 	return json.NewEncoder(w).Encode(response)
 }
 
-// encodeHTTPQueryAdvResponse
-func encodeHTTPQueryAdvResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+// encodeHTTPQRCodeResponse writes the image of the qr code, the errors are
+// encoded as JSON.
+func encodeHTTPQRCodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
 	if f, ok := response.(kitendpoint.Failer); ok && f.Failed() != nil {
 		errorEncoder(ctx, f.Failed(), w)
 		return nil
 	}
-	resp, ok := response.(endpoint.QueryResponse)
+	resp, ok := response.(endpoint.QRCodeResponse)
 	if !ok {
-		errorEncoder(ctx, ErrReponseAssert, w)
+		return ErrReponseAssert
+	}
+	w.Header().Set("Content-Type", resp.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Data)))
+	_, err := w.Write(resp.Data)
+	return err
+}
+
+// newHTTPRedirectEncoder returns the transport/http.EncodeResponseFunc that
+// redirects to the long URL, by the status of the type of the link or the
+// config, or by the interstitial page. The errors are returned to the error
+// encoder of the server, which is aware of browsers.
+func newHTTPRedirectEncoder(conf config.Redirect, interstitialPage *template.Template) kithttp.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		if f, ok := response.(kitendpoint.Failer); ok && f.Failed() != nil {
+			return f.Failed()
+		}
+		resp, ok := response.(endpoint.QueryResponse)
+		if !ok {
+			return ErrReponseAssert
+		}
+
+		longURL := resp.LongURL
+		if conf.ForwardQuery {
+			requestURI, _ := ctx.Value(kithttp.ContextKeyRequestURI).(string)
+			longURL = forwardQuery(longURL, requestURI)
+		}
+		redirect := resp.Redirect
+		if redirect == "" {
+			redirect = conf.Type
+		}
+		if conf.CacheControl != "" {
+			w.Header().Set("Cache-Control", conf.CacheControl)
+		}
+
+		if redirect == service.RedirectInterstitial {
+			var page bytes.Buffer
+			err := interstitialPage.Execute(&page, struct {
+				URL   string
+				Title string
+				Delay int
+			}{longURL, resp.Title, conf.InterstitialDelay})
+			if err != nil {
+				return err
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, err = w.Write(page.Bytes())
+			return err
+		}
+
+		status, ok := redirectStatus[redirect]
+		if !ok {
+			status = http.StatusFound
+		}
+		w.Header().Set("Location", longURL)
+		w.WriteHeader(status)
 		return nil
 	}
+}
 
-	w.Header().Set("Location", resp.LongURL)
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusFound)
+// forwardQuery merges the query string of the request URI onto the long URL,
+// the parameters of the long URL are kept.
+func forwardQuery(longURL string, requestURI string) string {
+	i := strings.IndexByte(requestURI, '?')
+	if i < 0 {
+		return longURL
+	}
+	// the malformed pairs are dropped
+	query, _ := url.ParseQuery(requestURI[i+1:])
+	if len(query) == 0 {
+		return longURL
+	}
 
-	return nil
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return longURL
+	}
+	for key := range u.Query() {
+		delete(query, key)
+	}
+	if len(query) == 0 {
+		return longURL
+	}
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += query.Encode()
+	return u.String()
 }
@@ -0,0 +1,346 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/WiFeng/short-url/pkg/core/config"
+	"github.com/WiFeng/short-url/pkg/core/log"
+	"github.com/WiFeng/short-url/pkg/dao"
+	"github.com/WiFeng/short-url/pkg/endpoint"
+	"github.com/WiFeng/short-url/pkg/ratelimit"
+	"github.com/WiFeng/short-url/pkg/service"
+)
+
+// newTestLogger returns the logger of the errors, to stderr unless the output
+// paths are set.
+func newTestLogger(t *testing.T, conf *config.Config) log.Logger {
+	conf.Server.Log.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
+	conf.Server.Log.Encoding = "console"
+	if len(conf.Server.Log.OutputPaths) == 0 {
+		conf.Server.Log.OutputPaths = []string{"stderr"}
+	}
+	logger, err := log.NewLogger(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+// newTestServer serves the handler of the memory storage, the redirects are
+// limited to a burst of 2 per client. The config may be changed by configure.
+func newTestServer(t *testing.T, configure ...func(conf *config.Config)) *httptest.Server {
+	conf := &config.Config{}
+	conf.General.ShortDomain = "http://sh.url/"
+	conf.General.Domains = []string{"https://go.brand.com/"}
+	conf.RateLimit.Enabled = true
+	conf.RateLimit.Backend = ratelimit.BackendMemory
+	conf.RateLimit.Redirect = config.Rate{Rate: 0.001, Burst: 2}
+	for _, f := range configure {
+		f(conf)
+	}
+	logger := newTestLogger(t, conf)
+	// the loggers of the requests are derived from the default one
+	log.SetDefaultLogger(logger)
+
+	limits, err := ratelimit.New(conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := service.New(conf, dao.NewMemoryStorage(), nil, logger)
+	endpoints := endpoint.New(svc, nil, nil, limits, logger)
+	srv := httptest.NewServer(NewHTTPHandler(endpoints, conf, logger))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPCreateRedirect(t *testing.T) {
+	srv := newTestServer(t)
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Post(srv.URL+"/admin/create", "application/json",
+		strings.NewReader(`{"long_url": "https://github.com/wifeng/short-url"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created endpoint.CreateResponse
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("create: got %d, %v", resp.StatusCode, err)
+	}
+	if created.ShortURL != "http://sh.url/2bI" {
+		t.Fatalf("create: got %s", created.ShortURL)
+	}
+
+	code := created.ShortURL[strings.LastIndex(created.ShortURL, "/")+1:]
+	for i := 0; i < 2; i++ {
+		resp, err = client.Get(srv.URL + "/" + code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("redirect: got %d, want 302", resp.StatusCode)
+		}
+		if location := resp.Header.Get("Location"); location != "https://github.com/wifeng/short-url" {
+			t.Fatalf("redirect: got location %s", location)
+		}
+	}
+
+	// the burst is taken
+	resp, err = client.Get(srv.URL + "/" + code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("redirect: got %d, want 429", resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Fatal("redirect: no Retry-After")
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	srv := newTestServer(t)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"POST", "/admin/create", `{"long_url": "ftp://example.com/"}`, http.StatusBadRequest},
+		{"POST", "/admin/create", `{"long_url": `, http.StatusBadRequest},
+		{"POST", "/admin/query", `{"short_url": "http://sh.url/nope"}`, http.StatusNotFound},
+		{"GET", "/nope", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, srv.URL+c.path, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %s %s: got %d, want %d", c.method, c.path, c.body, resp.StatusCode, c.status)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	conf := &config.Config{}
+	conf.Server.Log.OutputPaths = []string{os.DevNull}
+	proxies := parseProxies([]string{"10.0.0.0/8", "192.0.2.1", "::1", "not a proxy"}, newTestLogger(t, conf))
+	if len(proxies) != 3 {
+		t.Fatalf("got %d proxies, want the invalid one skipped", len(proxies))
+	}
+
+	cases := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"untrusted peer", "203.0.113.9:1234", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.9"},
+		{"trusted peer without headers", "10.1.2.3:1234", nil, "", "10.1.2.3"},
+		{"real ip", "192.0.2.1:1234", nil, "198.51.100.2", "198.51.100.2"},
+		{"forwarded", "10.1.2.3:1234", []string{"198.51.100.1"}, "198.51.100.2", "198.51.100.1"},
+		{"forged left hops", "10.1.2.3:1234", []string{"1.1.1.1, 198.51.100.1, 10.2.0.1"}, "", "198.51.100.1"},
+		{"multiple headers", "[::1]:1234", []string{"1.1.1.1", "198.51.100.1 , 10.2.0.1"}, "", "198.51.100.1"},
+		{"only proxies", "10.1.2.3:1234", []string{"10.3.0.1, ,10.2.0.1"}, "", "10.3.0.1"},
+		{"peer without port", "203.0.113.9", nil, "", "203.0.113.9"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = c.remote
+			for _, v := range c.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if c.realIP != "" {
+				r.Header.Set("X-Real-IP", c.realIP)
+			}
+			if got := proxies.clientIP(r); got != c.want {
+				t.Fatalf("got %s, want %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestHTTPAdminShortDomain(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Post(srv.URL+"/admin/create", "application/json",
+		strings.NewReader(`{"long_url": "https://example.com/", "domain": "go.brand.com"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create: got %d", resp.StatusCode)
+	}
+
+	cases := []struct {
+		query  string
+		status int
+	}{
+		{"?short_domain=go.brand.com", http.StatusOK},
+		{"?short_domain=https://go.brand.com/", http.StatusOK},
+		// the domain filters the long URLs, the code is of the default domain
+		{"?domain=go.brand.com", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		for _, path := range []string{"/admin/stats/2bI", "/admin/qr/2bI"} {
+			resp, err := http.Get(srv.URL + path + c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != c.status {
+				t.Errorf("%s%s: got %d, want %d", path, c.query, resp.StatusCode, c.status)
+			}
+		}
+	}
+}
+
+func TestHTTPQRCode(t *testing.T) {
+	// the qr codes of the links are limited like the redirects
+	srv := newTestServer(t, func(conf *config.Config) {
+		conf.RateLimit.Redirect = config.Rate{}
+	})
+
+	resp, err := http.Post(srv.URL+"/admin/create", "application/json",
+		strings.NewReader(`{"long_url": "https://example.com/"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	cases := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{"/2bI.png", http.StatusOK, "image/png"},
+		{"/2bI.svg?size=512&foreground=1e90ff", http.StatusOK, "image/svg+xml"},
+		{"/x/2bI.svg", http.StatusOK, "image/svg+xml"},
+		{"/admin/qr/2bI?format=svg", http.StatusOK, "image/svg+xml"},
+		{"/2bI.png?size=big", http.StatusBadRequest, ""},
+		{"/2bI.png?size=100000", http.StatusBadRequest, ""},
+		{"/2bI.svg?level=X", http.StatusBadRequest, ""},
+		{"/2bI.svg?background=white", http.StatusBadRequest, ""},
+		{"/2bI.png?margin=-1", http.StatusBadRequest, ""},
+		{"/admin/qr/2bI?format=gif", http.StatusBadRequest, ""},
+		{"/nope.png", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(srv.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: got %d, want %d", c.path, resp.StatusCode, c.status)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != c.contentType {
+			t.Errorf("%s: got content type %s, want %s", c.path, ct, c.contentType)
+		}
+		if c.contentType == "image/png" {
+			if _, err := png.Decode(bytes.NewReader(body)); err != nil {
+				t.Errorf("%s: %v", c.path, err)
+			}
+		} else if !bytes.HasPrefix(body, []byte("<svg ")) {
+			t.Errorf("%s: got %.40s, want an svg", c.path, body)
+		}
+	}
+}
+
+func TestHTTPRedirectTypes(t *testing.T) {
+	srv := newTestServer(t, func(conf *config.Config) {
+		conf.RateLimit.Redirect = config.Rate{}
+		conf.Redirect.Type = "301"
+		conf.Redirect.CacheControl = "private, max-age=90"
+		conf.Redirect.ForwardQuery = true
+	})
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	cases := []struct {
+		redirect string
+		query    string
+		status   int
+		location string
+	}{
+		{"", "", http.StatusMovedPermanently, "https://example.com/?a=1"},
+		{"302", "", http.StatusFound, "https://example.com/302?a=1"},
+		{"307", "?b=2&a=3", http.StatusTemporaryRedirect, "https://example.com/307?a=1&b=2"},
+		{"308", "", http.StatusPermanentRedirect, "https://example.com/308?a=1"},
+		{"interstitial", "", http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		body := `{"long_url": "https://example.com/` + c.redirect + `?a=1", "redirect": "` + c.redirect + `", "title": "<b>Sale</b>"}`
+		resp, err := client.Post(srv.URL+"/admin/create", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var created endpoint.CreateResponse
+		err = json.NewDecoder(resp.Body).Decode(&created)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("create of %q: got %d, %v", c.redirect, resp.StatusCode, err)
+		}
+
+		code := created.ShortURL[strings.LastIndex(created.ShortURL, "/")+1:]
+		resp, err = client.Get(srv.URL + "/" + code + c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != c.status || resp.Header.Get("Location") != c.location {
+			t.Errorf("redirect %q: got %d to %s, want %d to %s", c.redirect, resp.StatusCode,
+				resp.Header.Get("Location"), c.status, c.location)
+		}
+		if cc := resp.Header.Get("Cache-Control"); cc != "private, max-age=90" {
+			t.Errorf("redirect %q: got Cache-Control %q", c.redirect, cc)
+		}
+		if c.redirect == "interstitial" {
+			if !bytes.Contains(page, []byte("https://example.com/interstitial?a=1")) || bytes.Contains(page, []byte("<b>")) {
+				t.Errorf("got the interstitial page %s", page)
+			}
+		}
+	}
+}